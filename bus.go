@@ -0,0 +1,135 @@
+package consultant
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// NotificationBus aggregates the notifications of any number of producers, re-publishing each with the producer's
+// identity set in Notification.Producer.  Subscribers attach to the bus exactly as they would to any other Notifier,
+// and may use the same filtering options, e.g. WithNotificationSources(NotificationSourceCandidate).
+//
+// Managed types may be registered manually with Register, or join a bus on construction by setting NotificationBus in
+// their config.
+type NotificationBus struct {
+	*notifierBase
+
+	mu        sync.Mutex
+	producers map[string]*notificationBusProducer
+}
+
+type notificationBusProducer struct {
+	n      Notifier
+	cancel context.CancelFunc
+}
+
+// NewNotificationBus constructs a new, empty NotificationBus
+func NewNotificationBus(log Logger, debug bool) *NotificationBus {
+	b := new(NotificationBus)
+	b.notifierBase = newNotifierBase(buildStructuredLogger(nil, log, debug, LogKeyComponent, LogComponentNotificationBus))
+	b.producers = make(map[string]*notificationBusProducer)
+	return b
+}
+
+// Register begins re-publishing all notifications from n with the provided producer identity, returning that identity.
+// If id is empty, a random one is generated.  If a producer was already registered with the same id, it is replaced.
+//
+// The bus attaches to n with a blocking overflow policy so that no notifications are lost between producer and bus,
+// meaning a bus subscriber that itself uses a blocking policy may apply backpressure all the way to the producer.
+func (b *NotificationBus) Register(id string, n Notifier) string {
+	if n == nil {
+		panic(fmt.Sprintf("Register called with id %q and nil notifier", id))
+	}
+	return b.register(id, n, true)
+}
+
+// join registers n with the provided producer identity, appending a random suffix if the identity is already taken so
+// that an existing producer is never replaced.  It is used by managed types joining the bus on construction, whose
+// default identities may well be shared by others in the same process.
+func (b *NotificationBus) join(id string, n Notifier) string {
+	return b.register(id, n, false)
+}
+
+func (b *NotificationBus) register(id string, n Notifier, replace bool) string {
+	if id == "" {
+		id = LazyRandomString(12)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	b.mu.Lock()
+	prev, replaced := b.producers[id]
+	if replaced && !replace {
+		base := id
+		for replaced {
+			id = base + "/" + LazyRandomString(8)
+			_, replaced = b.producers[id]
+		}
+		b.log.Debug("Producer identity already registered, using suffixed identity", "requested", base, "producer", id)
+	}
+	b.producers[id] = &notificationBusProducer{n: n, cancel: cancel}
+	b.mu.Unlock()
+
+	if replaced {
+		b.log.Debug("Replacing producer", "producer", id)
+		prev.cancel()
+	}
+
+	n.AttachNotificationHandlerContext(ctx, func(n Notification) {
+		n.Producer = id
+		b.dispatch(n, 0)
+	}, WithNotificationBuffer(NotificationDefaultBufferSize, NotificationOverflowBlock))
+
+	b.log.Debug("Producer registered", "producer", id)
+
+	return id
+}
+
+// leaveNotificationBus deregisters the producer with the provided id from b, if defined.  It is used to undo a join
+// when construction of the producer fails.
+func leaveNotificationBus(b *NotificationBus, id string) {
+	if b != nil {
+		b.Deregister(id)
+	}
+}
+
+// Deregister stops re-publishing notifications from the producer registered with the provided id, returning true if
+// one was found
+func (b *NotificationBus) Deregister(id string) bool {
+	b.mu.Lock()
+	p, ok := b.producers[id]
+	delete(b.producers, id)
+	b.mu.Unlock()
+
+	if ok {
+		p.cancel()
+		b.log.Debug("Producer deregistered", "producer", id)
+	}
+
+	return ok
+}
+
+// Producers returns the sorted identities of all currently registered producers
+func (b *NotificationBus) Producers() []string {
+	b.mu.Lock()
+	out := make([]string, 0, len(b.producers))
+	for id := range b.producers {
+		out = append(out, id)
+	}
+	b.mu.Unlock()
+	sort.Strings(out)
+	return out
+}
+
+// Close deregisters all producers and detaches all subscribers
+func (b *NotificationBus) Close() {
+	b.mu.Lock()
+	for id, p := range b.producers {
+		p.cancel()
+		delete(b.producers, id)
+	}
+	b.mu.Unlock()
+	b.DetachAllNotificationRecipients(false)
+}
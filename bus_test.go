@@ -0,0 +1,158 @@
+package consultant_test
+
+import (
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/myENA/consultant/v2"
+)
+
+func TestNotificationBus(t *testing.T) {
+	var (
+		logger = log.New(os.Stdout, "==> Notifier ", log.LstdFlags)
+		bus    = consultant.NewNotificationBus(logger, true)
+		p1     = consultant.NewBasicNotifier(logger, true)
+		p2     = consultant.NewBasicNotifier(logger, true)
+	)
+
+	defer bus.Close()
+
+	if id := bus.Register("p1", p1); id != "p1" {
+		t.Logf("Expected id %q, saw %q", "p1", id)
+		t.Fail()
+	}
+	p2id := bus.Register("", p2)
+	if p2id == "" {
+		t.Fatal("Expected random producer id to be generated")
+	}
+	if l := len(bus.Producers()); l != 2 {
+		t.Logf("Expected 2 producers, saw %d", l)
+		t.Fail()
+	}
+
+	all := make(consultant.NotificationChannel, 10)
+	bus.AttachNotificationChannel("all", all)
+
+	candidates := make(consultant.NotificationChannel, 10)
+	bus.AttachNotificationChannel("candidates", candidates, consultant.WithNotificationSources(consultant.NotificationSourceCandidate))
+
+	p1.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, "one")
+	p2.Push(consultant.NotificationSourceCandidate, consultant.NotificationEventTestPush, "two")
+
+	seen := make(map[string]string)
+	for i := 0; i < 2; i++ {
+		select {
+		case n := <-all:
+			seen[n.Producer] = n.Data.(string)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected notification within 5 seconds")
+		}
+	}
+	if seen["p1"] != "one" || seen[p2id] != "two" {
+		t.Logf("Unexpected producers seen: %v", seen)
+		t.Fail()
+	}
+
+	select {
+	case n := <-candidates:
+		if n.Producer != p2id {
+			t.Logf("Expected producer %q, saw %q", p2id, n.Producer)
+			t.Fail()
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected candidate notification within 5 seconds")
+	}
+
+	if !bus.Deregister("p1") {
+		t.Fatal("Expected p1 to be deregistered")
+	}
+	time.Sleep(100 * time.Millisecond)
+	p1.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, "three")
+
+	select {
+	case n := <-all:
+		t.Logf("Expected no notification after deregister, saw %v", n)
+		t.Fail()
+	case <-time.After(250 * time.Millisecond):
+	}
+}
+
+func TestNotificationBus_Join(t *testing.T) {
+	var (
+		logger = log.New(os.Stdout, "==> Notifier ", log.LstdFlags)
+		bus    = consultant.NewNotificationBus(logger, true)
+	)
+
+	defer bus.Close()
+
+	// sessions sharing a name, as they would by default within a single process, must not replace one another
+	for i := 0; i < 2; i++ {
+		cfg := new(consultant.ManagedSessionConfig)
+		cfg.Definition = &api.SessionEntry{Name: "shared", Node: "test"}
+		cfg.NotificationBus = bus
+		if _, err := consultant.NewManagedSession(cfg); err != nil {
+			t.Fatalf("Error creating session %d: %s", i, err)
+		}
+	}
+
+	producers := bus.Producers()
+	if len(producers) != 2 {
+		t.Fatalf("Expected 2 producers, saw %v", producers)
+	}
+	if producers[0] != "shared" {
+		t.Logf("Expected first producer to keep its name, saw %v", producers)
+		t.Fail()
+	}
+	if !strings.HasPrefix(producers[1], "shared/") {
+		t.Logf("Expected second producer to have a suffixed name, saw %v", producers)
+		t.Fail()
+	}
+}
+
+func TestNotificationBus_Replay(t *testing.T) {
+	var (
+		logger = log.New(os.Stdout, "==> Notifier ", log.LstdFlags)
+		bus    = consultant.NewNotificationBus(logger, true)
+		p1     = consultant.NewBasicNotifier(logger, true)
+		p2     = consultant.NewBasicNotifier(logger, true)
+	)
+
+	defer bus.Close()
+
+	bus.Register("p1", p1)
+	bus.Register("p2", p2)
+
+	p1.Push(consultant.NotificationSourceCandidate, consultant.NotificationEventTestPush, "one")
+	p2.Push(consultant.NotificationSourceCandidate, consultant.NotificationEventTestPush, "two")
+
+	for i := 0; i < 100 && len(bus.LastNotifications()) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// a late subscriber must be replayed the latest state of every producer, not just whichever sent last
+	replayed := make(consultant.NotificationChannel, 10)
+	bus.AttachNotificationChannel("late", replayed, consultant.WithNotificationReplay())
+
+	seen := make(map[string]string)
+	for i := 0; i < 2; i++ {
+		select {
+		case n := <-replayed:
+			seen[n.Producer] = n.Data.(string)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected 2 replayed notifications within 5 seconds, saw %v", seen)
+		}
+	}
+	if seen["p1"] != "one" || seen["p2"] != "two" {
+		t.Logf("Unexpected replay: %v", seen)
+		t.Fail()
+	}
+
+	if n, ok := bus.LastNotification(consultant.NotificationSourceCandidate, consultant.NotificationEventTestPush); !ok || n.Producer == "" {
+		t.Logf("Expected last notification to be found with its producer, saw %v (%t)", n, ok)
+		t.Fail()
+	}
+}
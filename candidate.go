@@ -104,6 +104,12 @@ type CandidateConfig struct {
 	// Logger for logging.  No logger means no logging.  Allows for a separate logger instance to be used from the
 	// underlying ManagedSession instance.
 	Logger Logger
+
+	// StructuredLogger [optional]
+	//
+	// Levelled, structured logger to use in place of Logger.  If defined, Logger and Debug are ignored.  Allows for a
+	// separate logger instance to be used from the underlying ManagedSession instance.
+	StructuredLogger StructuredLogger
 }
 
 // Candidate represents an extension to the ManagedSession type that will additionally attempt to apply the session
@@ -119,11 +125,9 @@ type Candidate struct {
 	kvKey           string
 	kvValueProvider CandidateLeaderKVValueProvider
 	elected         *bool
+	electedAt       time.Time
 	state           CandidateState
 
-	dbg bool
-	log Logger
-
 	consecutiveSessionErrors *uint64
 	stop                     chan chan error
 }
@@ -147,23 +151,28 @@ func NewCandidate(conf *CandidateConfig) (*Candidate, error) {
 		return nil, fmt.Errorf("error constructing ManagedSession: %s", err)
 	}
 
-	c.log = conf.Logger
-	c.dbg = conf.Debug
+	c.notifierBase = newNotifierBase(buildStructuredLogger(
+		conf.StructuredLogger,
+		conf.Logger,
+		conf.Debug,
+		LogKeyComponent, LogComponentCandidate,
+	))
 
 	if conf.ID == "" {
 		if addr, err := LocalAddress(); err != nil {
 			id = LazyRandomString(8)
-			c.logf(false, "No ID defined in config and error returned from LocalAddress (%s).  Setting ID to %q", err, id)
+			c.log.Warn("No ID defined in config and error returned from LocalAddress, using random ID", LogKeyCandidateID, id, LogKeyError, err)
 		} else {
 			id = addr
-			c.logf(true, "No ID defined, setting ID to %q", id)
+			c.log.Debug("No ID defined, using local address", LogKeyCandidateID, id)
 		}
 	} else {
 		id = conf.ID
 	}
 
 	c.id = id
-	c.notifierBase = newNotifierBase(c.log, c.dbg)
+	c.log = c.log.With(LogKeyCandidateID, c.id)
+	c.metrics = conf.Metrics
 	c.kvKey = conf.KVKey
 	c.consecutiveSessionErrors = new(uint64)
 	*c.consecutiveSessionErrors = 0
@@ -178,9 +187,17 @@ func NewCandidate(conf *CandidateConfig) (*Candidate, error) {
 
 	c.ms.AttachNotificationHandler(fmt.Sprintf("candidate_%s", c.id), c.sessionUpdate)
 
+	// the candidate's id alone is not unique within a process, as it defaults to the local address
+	var busID string
+	if conf.NotificationBus != nil {
+		busID = conf.NotificationBus.join(c.id+"/"+c.kvKey, c)
+	}
+
 	if conf.StartImmediately {
-		c.logf(true, "StartImmediately enabled")
+		c.log.Debug("StartImmediately enabled")
 		if err := c.Run(); err != nil {
+			leaveNotificationBus(conf.NotificationBus, busID)
+			leaveNotificationBus(conf.NotificationBus, c.ms.busID)
 			return nil, fmt.Errorf("error occurred during auto run: %s", err)
 		}
 	}
@@ -273,14 +290,14 @@ func (c *Candidate) WaitUntil(ctx context.Context) error {
 		}
 		select {
 		case <-ctx.Done():
-			c.logf(false, "Context finished before locating leader: %s", ctx.Err())
+			c.log.Warn("Context finished before locating leader", LogKeyError, ctx.Err())
 			return ctx.Err()
 
 		default:
 			if _, _, err := c.LeaderSession(ctx); nil == err {
 				return nil
 			} else {
-				c.logf(false, "Attempt %d at locating leader service errored: %s", i, err)
+				c.log.Warn("Error locating leader session", "attempt", i, LogKeyError, err)
 			}
 		}
 
@@ -341,19 +358,19 @@ func (c *Candidate) Run() error {
 
 	c.setState(CandidateStateRunning)
 
-	c.logf(false, "Run() - Entering election pool...")
-	c.logf(true, "Run() - Starting up managed session...")
+	c.log.Info("Entering election pool")
+	c.log.Debug("Starting up managed session")
 
 	if err := c.ms.Run(); err != nil {
 		return fmt.Errorf("session for candidate could not be started: %s", err)
 	}
 
-	c.logf(true, "Run() - Managed session started with ID %q", c.ms.ID())
+	c.log.Debug("Managed session started", LogKeySessionID, c.ms.ID())
 
 	// start up the lock maintainer
 	go c.maintainLock()
 
-	return c.refreshLock()
+	return c.refreshLock("")
 }
 
 // Resign will remove this candidate from the election pool
@@ -361,12 +378,12 @@ func (c *Candidate) Resign() error {
 	c.mu.Lock()
 	if c.state == CandidateStateResigned {
 		c.mu.Unlock()
-		c.logf(true, "Resign() called but we're already resigned")
+		c.log.Debug("Resign() called but we're already resigned")
 		return nil
 	}
 	if c.state == CandidateStateShutdowned {
 		c.mu.Unlock()
-		c.logf(false, "Resign() called but we're shutdowned")
+		c.log.Info("Resign() called but we're shutdowned")
 		return nil
 	}
 
@@ -382,7 +399,7 @@ func (c *Candidate) Shutdown() error {
 	c.mu.Lock()
 	if c.state == CandidateStateShutdowned {
 		c.mu.Unlock()
-		c.logf(true, "Shutdown() called but we're already shutdowned")
+		c.log.Debug("Shutdown() called but we're already shutdowned")
 		return nil
 	}
 
@@ -428,17 +445,31 @@ func (c *Candidate) buildUpdate(err error) CandidateUpdate {
 	}
 }
 
+// recordElectionChange tracks when this candidate was elected, recording the transition if metrics are enabled
+//
+// caller must hold full lock
+func (c *Candidate) recordElectionChange(elected bool) {
+	var leaderFor time.Duration
+	if elected {
+		c.electedAt = time.Now()
+	} else if !c.electedAt.IsZero() {
+		leaderFor = time.Since(c.electedAt)
+		c.electedAt = time.Time{}
+	}
+	if c.metrics != nil {
+		c.metrics.CandidateElectionChanged(c.id, elected, leaderFor)
+	}
+}
+
 // pushNotification constructs and then pushes a new notification to currently registered recipients based on the
 // current state of the candidate.
 func (c *Candidate) pushNotification(ev NotificationEvent, up CandidateUpdate) {
-	c.sendNotification(NotificationSourceCandidate, ev, up)
+	c.pushCausedNotification("", ev, up)
 }
 
-func (c *Candidate) logf(debug bool, f string, v ...interface{}) {
-	if c.log == nil || (debug && !c.dbg) {
-		return
-	}
-	c.log.Printf(f, v...)
+// pushCausedNotification is identical to pushNotification, but links the notification to the one that caused it
+func (c *Candidate) pushCausedNotification(cause string, ev NotificationEvent, up CandidateUpdate) {
+	c.sendCausedNotification(cause, NotificationSourceCandidate, ev, up)
 }
 
 func (c *Candidate) waitForResign() error {
@@ -490,17 +521,27 @@ func (c *Candidate) acquire() (bool, error) {
 
 	kvp.Value, err = c.kvValueProvider(c)
 	if err != nil {
-		c.logf(false, "Unable to marshal LeaderKV body: %s", err)
+		c.log.Error("Unable to marshal LeaderKV body", LogKeyError, err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.ms.requestTTL)
+	ctx, span := startSpan(
+		c.ms.traceCtx,
+		c.ms.tracer,
+		TraceSpanKVAcquire,
+		TraceAttributeKVKey.String(kvp.Key),
+		TraceAttributeSessionID.String(kvp.Session),
+		TraceAttributeCandidateID.String(c.id),
+	)
+	ctx, cancel := context.WithTimeout(ctx, c.ms.requestTTL)
 	defer cancel()
 	elected, _, err = c.ms.client.KV().Acquire(kvp, c.ms.wo.WithContext(ctx))
+	endSpan(span, err)
 	return elected, err
 }
 
-// refreshLock is responsible for attempting to create / refresh the session lock on the kv
-func (c *Candidate) refreshLock() error {
+// refreshLock is responsible for attempting to create / refresh the session lock on the kv.  cause is the ID of the
+// notification that triggered this refresh, if any, and is attached to any resulting notification.
+func (c *Candidate) refreshLock(cause string) error {
 	var (
 		elected, updated bool
 		err              error
@@ -512,22 +553,22 @@ func (c *Candidate) refreshLock() error {
 			// this should only ever happen very early on in the election process
 			elected = false
 			updated = c.elected != nil && *c.elected != elected
-			c.logf(false, "refreshLock() - ManagedSession does not exist, will try locking again in %d seconds...", int64(c.ms.RenewInterval().Seconds()))
+			c.log.Info("ManagedSession does not exist, will try locking again", "retry_in", c.ms.RenewInterval())
 		} else if elected, err = c.acquire(); err != nil {
 			// most likely hit due to transport error.
 			updated = c.elected != nil && *c.elected != elected
-			c.logf(false, "refreshLock() - Error attempting to acquire lock: %s", err)
+			c.log.Error("Error attempting to acquire lock", LogKeySessionID, sid, "key", c.kvKey, LogKeyError, err)
 		} else {
 			// if c.elected is nil, indicating this is the initial election loop, or if the election state
 			// changed mark update as true
 			updated = c.elected == nil || *c.elected != elected
 		}
 	} else {
-		c.logf(false, "refreshLock() - ManagedSession is in stopped state, attempting to restart...")
+		c.log.Warn("ManagedSession is in stopped state, attempting to restart")
 		elected = false
 		updated = c.elected != nil && *c.elected != elected
 		if err := c.ms.Run(); err != nil {
-			c.logf(false, "refreshLock() - Error restarting ManagedSession: %s", err)
+			c.log.Error("Error restarting ManagedSession", LogKeyError, err)
 		}
 	}
 
@@ -535,6 +576,7 @@ func (c *Candidate) refreshLock() error {
 	if updated {
 		// update internal state
 		*c.elected = elected
+		c.recordElectionChange(elected)
 	}
 
 	up := c.buildUpdate(err)
@@ -542,15 +584,15 @@ func (c *Candidate) refreshLock() error {
 	// if our state changed, notify accordingly
 	if updated {
 		if elected {
-			c.logf(false, "refreshLock() - We have won the election")
-			c.pushNotification(NotificationEventCandidateElected, up)
+			c.log.Info("We have won the election", LogKeySessionID, c.ms.ID(), LogKeyEvent, NotificationEventCandidateElected.String())
+			c.pushCausedNotification(cause, NotificationEventCandidateElected, up)
 		} else {
-			c.logf(false, "refreshLock() - We have lost the election")
-			c.pushNotification(NotificationEventCandidateLostElection, up)
+			c.log.Info("We have lost the election", LogKeyEvent, NotificationEventCandidateLostElection.String())
+			c.pushCausedNotification(cause, NotificationEventCandidateLostElection, up)
 		}
 	} else if elected {
 		// if we were already elected, push "renewed" notification
-		c.pushNotification(NotificationEventCandidateRenew, up)
+		c.pushCausedNotification(cause, NotificationEventCandidateRenew, up)
 	}
 
 	return err
@@ -558,7 +600,7 @@ func (c *Candidate) refreshLock() error {
 
 // sessionUpdate is the receiver for the session update callback
 func (c *Candidate) sessionUpdate(n Notification) {
-	c.logf(true, "sessionUpdate() - Notification received: %v", n.Data)
+	c.log.Debug("Session notification received", LogKeyEvent, n.Event.String(), "notification_id", n.ID)
 	if !c.Running() {
 		return
 	}
@@ -568,7 +610,7 @@ func (c *Candidate) sessionUpdate(n Notification) {
 
 	update, ok := n.Data.(ManagedSessionUpdate)
 	if !ok {
-		c.logf(false, "sessionUpdate() - Expected data to be of type %T, saw %T", ManagedSessionUpdate{}, n.Data)
+		c.log.Error("Unexpected session notification data type", "expected", fmt.Sprintf("%T", ManagedSessionUpdate{}), "saw", fmt.Sprintf("%T", n.Data))
 		return
 	}
 
@@ -576,12 +618,17 @@ func (c *Candidate) sessionUpdate(n Notification) {
 	if update.Error != nil {
 		// if there was an update either creating or renewing our session
 		atomic.AddUint64(c.consecutiveSessionErrors, 1)
-		c.logf(false, "sessionUpdate() - Error (%d in a row): %s", atomic.LoadUint64(c.consecutiveSessionErrors), update.Error)
+		c.log.Warn(
+			"Session error seen",
+			LogKeySessionID, update.ID,
+			"consecutive", atomic.LoadUint64(c.consecutiveSessionErrors),
+			LogKeyError, update.Error,
+		)
 		if update.State == ManagedSessionStateRunning && atomic.LoadUint64(c.consecutiveSessionErrors) > 2 {
 			// if the session is still running but we've seen more than 2 errors, attempt a stop -> start cycle
-			c.logf(false, "sessionUpdate() - 2 successive errors seen, stopping session")
+			c.log.Warn("2 successive session errors seen, stopping session", LogKeySessionID, update.ID)
 			if err := c.ms.Stop(); err != nil {
-				c.logf(false, "sessionUpdate() - Error stopping session: %s", err)
+				c.log.Error("Error stopping session", LogKeySessionID, update.ID, LogKeyError, err)
 			}
 			refresh = true
 		}
@@ -593,16 +640,16 @@ func (c *Candidate) sessionUpdate(n Notification) {
 		// on.  next acquire tick will attempt to restart session.
 		atomic.StoreUint64(c.consecutiveSessionErrors, 0)
 		refresh = true
-		c.logf(false, "sessionUpdate() - Stopped state seen: %#v", update)
+		c.log.Info("Session stopped state seen", LogKeySessionID, update.ID)
 	} else {
 		// if we got a non-error / non-stopped update, there is nothing to do.
 		atomic.StoreUint64(c.consecutiveSessionErrors, 0)
-		c.logf(true, "sessionUpdate() - Received %#v", update)
+		c.log.Debug("Session update received", LogKeySessionID, update.ID, "state", update.State.String())
 	}
 
 	if refresh {
-		c.logf(false, "sessionUpdate() - refreshing lock")
-		_ = c.refreshLock()
+		c.log.Info("Refreshing lock")
+		_ = c.refreshLock(n.ID)
 	}
 }
 
@@ -611,21 +658,34 @@ func (c *Candidate) doStop() error {
 
 	// only update elected state if we were ever elected in the first place.
 	if c.elected != nil {
+		if *c.elected {
+			c.recordElectionChange(false)
+		}
 		*c.elected = false
 	}
 
-	c.logf(true, "doStop() - Deleting key %q", c.kvKey)
-	ctx, cancel := context.WithTimeout(context.Background(), c.ms.requestTTL)
+	c.log.Debug("Deleting key", "key", c.kvKey)
+	ctx, span := startSpan(
+		c.ms.traceCtx,
+		c.ms.tracer,
+		TraceSpanKVDelete,
+		TraceAttributeKVKey.String(c.kvKey),
+		TraceAttributeSessionID.String(c.ms.ID()),
+		TraceAttributeCandidateID.String(c.id),
+	)
+	ctx, cancel := context.WithTimeout(ctx, c.ms.requestTTL)
 	defer cancel()
-	if _, err := c.ms.client.KV().Delete(c.kvKey, c.ms.wo.WithContext(ctx)); err != nil {
-		c.logf(false, "doStop() - Error deleting key %q: %s", c.kvKey, err)
+	_, err = c.ms.client.KV().Delete(c.kvKey, c.ms.wo.WithContext(ctx))
+	endSpan(span, err)
+	if err != nil {
+		c.log.Error("Error deleting key", "key", c.kvKey, LogKeyError, err)
 	}
 
-	c.logf(true, "doStop() - Stopping managed session...")
+	c.log.Debug("Stopping managed session")
 	if err = c.ms.Stop(); err != nil {
-		c.logf(false, "doStop() - Error stopping candidate managed session (%s): %s", c.ms.ID(), err)
+		c.log.Error("Error stopping candidate managed session", LogKeySessionID, c.ms.ID(), LogKeyError, err)
 	} else {
-		c.logf(true, "doStop() - Managed session stopped")
+		c.log.Debug("Managed session stopped")
 	}
 
 	return err
@@ -633,7 +693,7 @@ func (c *Candidate) doStop() error {
 
 // maintainLock is responsible for triggering the routine that attempts to create / re-acquire the session kv lock
 func (c *Candidate) maintainLock() {
-	c.logf(true, "maintainLock() - Starting lock maintenance loop")
+	c.log.Debug("Starting lock maintenance loop")
 	var (
 		renewInterval = c.ms.RenewInterval()
 		renewTimer    = time.NewTimer(renewInterval)
@@ -642,14 +702,14 @@ func (c *Candidate) maintainLock() {
 	for {
 		select {
 		case tick := <-renewTimer.C:
-			c.logf(true, "maintainLock() - renewTimer tick (%s)", tick)
+			c.log.Debug("Renew interval reached", "tick", tick)
 			c.mu.Lock()
-			_ = c.refreshLock()
+			_ = c.refreshLock("")
 			c.mu.Unlock()
 			renewTimer.Reset(renewInterval)
 
 		case drop := <-c.stop:
-			c.logf(false, "maintainLock() - stop called")
+			c.log.Info("Stop called")
 			c.mu.Lock()
 			err := c.doStop()
 			c.mu.Unlock()
@@ -0,0 +1,796 @@
+package consultant
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/hcl"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	ServiceDefinitionDefaultReloadInterval = 5 * time.Second
+)
+
+var (
+	// serviceDefinitionBlockKeys are the keys whose value must be a single object.  HCL decodes every block as a list
+	// of objects, so these are flattened before being decoded.
+	//
+	// keys are stored in their normalized form, see normalizeServiceDefinitionKey.
+	serviceDefinitionBlockKeys = map[string]struct{}{
+		"check":            {},
+		"connect":          {},
+		"sidecarservice":   {},
+		"proxy":            {},
+		"weights":          {},
+		"meta":             {},
+		"header":           {},
+		"taggedaddresses":  {},
+		"config":           {},
+		"expose":           {},
+		"meshgateway":      {},
+		"transparentproxy": {},
+	}
+
+	// serviceDefinitionOpaqueKeys are the keys whose value is a user-defined map, and therefore must not have their
+	// keys normalized
+	serviceDefinitionOpaqueKeys = map[string]struct{}{
+		"meta":            {},
+		"header":          {},
+		"taggedaddresses": {},
+		"config":          {},
+	}
+)
+
+// ParseServiceDefinitions parses one or more service definitions in the same HCL or JSON format used by Consul agent
+// configuration files.  Both "service" blocks and a "services" list are supported, as are the "check" and "checks"
+// fields of each service.  Keys may be provided in either snake_case or CamelCase.
+//
+// Each definition is validated in the same way as a SimpleServiceRegistration: the name may not be blank or contain
+// spaces, and the port must be valid.  Definitions without an address will use the value of LocalAddress(), and those
+// without an id will have one formed from their name and the local hostname.  An error is returned if a definition has
+// no address and LocalAddress() fails.
+func ParseServiceDefinitions(b []byte) ([]*ManagedAgentServiceRegistration, error) {
+	var (
+		raw  map[string]interface{}
+		regs []*ManagedAgentServiceRegistration
+		err  error
+	)
+
+	if err = hcl.Decode(&raw, string(b)); err != nil {
+		return nil, fmt.Errorf("error decoding service definitions: %w", err)
+	}
+
+	localAddr, localAddrErr := LocalAddress()
+	localHostname, _ := os.Hostname()
+
+	for _, key := range []string{"service", "services"} {
+		v, ok := raw[key]
+		if !ok {
+			continue
+		}
+		delete(raw, key)
+
+		list, ok := v.([]map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%q must be a list of objects, saw %T", key, v)
+		}
+
+		for i, m := range list {
+			reg := new(api.AgentServiceRegistration)
+			if err = decodeServiceDefinition(m, reg); err != nil {
+				return nil, fmt.Errorf("error decoding %s[%d]: %w", key, i, err)
+			}
+			if err = validateServiceDefinition(localAddr, localAddrErr, localHostname, reg); err != nil {
+				return nil, fmt.Errorf("%s[%d] is invalid: %w", key, i, err)
+			}
+			regs = append(regs, NewManagedAgentServiceRegistration(reg))
+		}
+	}
+
+	if len(raw) > 0 {
+		keys := make([]string, 0, len(raw))
+		for k := range raw {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return nil, fmt.Errorf("unexpected keys in service definitions: %s", strings.Join(keys, ", "))
+	}
+
+	seen := make(map[string]struct{}, len(regs))
+	for _, reg := range regs {
+		if _, ok := seen[reg.ID]; ok {
+			return nil, fmt.Errorf("service id %q is defined more than once", reg.ID)
+		}
+		seen[reg.ID] = struct{}{}
+	}
+
+	return regs, nil
+}
+
+// LoadServiceDefinitionFile reads and parses the service definitions contained within the file at the provided path
+func LoadServiceDefinitionFile(path string) ([]*ManagedAgentServiceRegistration, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading service definition file %q: %w", path, err)
+	}
+	regs, err := ParseServiceDefinitions(b)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing service definition file %q: %w", path, err)
+	}
+	return regs, nil
+}
+
+// ServiceDefinitionLoaderUpdate is the value of .Data in all Notification pushes from a ServiceDefinitionLoader
+type ServiceDefinitionLoaderUpdate struct {
+	Paths   []string `json:"paths"`
+	Added   []string `json:"added"`
+	Updated []string `json:"updated"`
+	Removed []string `json:"removed"`
+	Error   error    `json:"error"`
+}
+
+// ServiceDefinitionLoaderConfig describes the basis for a new ServiceDefinitionLoader instance
+type ServiceDefinitionLoaderConfig struct {
+	// Paths [required]
+	//
+	// Files containing service definitions to load
+	Paths []string
+
+	// ServiceConfig [optional]
+	//
+	// Base configuration used when creating each ManagedService.  The ID, BaseChecks, and SidecarService fields are
+	// always overwritten with values from the service's definition.
+	ServiceConfig *ManagedServiceConfig
+
+	// ReloadInterval [optional]
+	//
+	// How often to check the definition files for change once running.  Defaults to value of
+	// ServiceDefinitionDefaultReloadInterval.
+	ReloadInterval time.Duration
+
+	// Logger [optional]
+	//
+	// Optionally specify a logger.  No logging will take place if one is not provided
+	Logger Logger
+
+	// Debug [optional]
+	//
+	// If true, will enable debug-level logging if a logger is provided
+	Debug bool
+
+	// StructuredLogger [optional]
+	//
+	// Optionally specify a levelled, structured logger to use in place of Logger.  If defined, Logger and Debug are
+	// ignored.  Services created without a logger of their own in ServiceConfig inherit the loader's.
+	StructuredLogger StructuredLogger
+
+	// Client [optional]
+	//
+	// Optionally provide a Consul client instance to use.  If one is not defined, a new one will be created with
+	// default configuration values.
+	Client *api.Client
+
+	// NotificationBus [optional]
+	//
+	// If defined, the loader will register itself with this bus on construction under a random producer identity.  To also register
+	// each loaded service, set NotificationBus in ServiceConfig.
+	NotificationBus *NotificationBus
+}
+
+// ServiceDefinitionLoader
+//
+// This type loads service definitions from one or more files, creating a ManagedService for each.  Once running, the
+// files are checked for change on an interval and the set of managed services is brought in line with their contents:
+// new definitions are registered and removed definitions are shut down.  Changed definitions whose only differences
+// are in their address, port, meta, weights, or tags are updated in place using the service's desired state and tags,
+// while any other change causes the service to be shut down and then registered again.
+type ServiceDefinitionLoader struct {
+	*notifierBase
+	mu sync.Mutex
+
+	// loadMu serializes loads, and is held for the duration of any calls made to services.  it must be acquired before
+	// mu.  mu is only held while reading or modifying loader state, so that accessors are not blocked by service I/O.
+	loadMu sync.Mutex
+
+	paths          []string
+	base           ManagedServiceConfig
+	reloadInterval time.Duration
+
+	// hash is the hash of the definition files' contents as of the last successful load.  failedHash is the hash of
+	// contents that last failed to parse, and failedErr the resulting error, so that the same contents are not parsed
+	// and reported again on every reload.  all three are only accessed while holding loadMu.
+	hash       []byte
+	failedHash []byte
+	failedErr  error
+
+	defs     map[string]*api.AgentServiceRegistration
+	services map[string]*ManagedService
+
+	running    bool
+	shutdowned bool
+	stop       chan chan struct{}
+}
+
+// NewServiceDefinitionLoader creates a new ServiceDefinitionLoader instance.  No definitions are loaded until either
+// Load or Run is called.
+func NewServiceDefinitionLoader(cfg *ServiceDefinitionLoaderConfig) (*ServiceDefinitionLoader, error) {
+	var (
+		err error
+
+		l = new(ServiceDefinitionLoader)
+	)
+
+	if cfg == nil {
+		return nil, errors.New("cfg cannot be nil")
+	}
+	if len(cfg.Paths) == 0 {
+		return nil, errors.New("at least one path must be set in config")
+	}
+
+	l.notifierBase = newNotifierBase(buildStructuredLogger(
+		cfg.StructuredLogger,
+		cfg.Logger,
+		cfg.Debug,
+		LogKeyComponent, LogComponentServiceDefinitionLoader,
+	))
+
+	l.paths = make([]string, len(cfg.Paths))
+	copy(l.paths, cfg.Paths)
+
+	if cfg.ServiceConfig != nil {
+		l.base = *cfg.ServiceConfig
+	}
+	if cfg.Client != nil {
+		l.base.Client = cfg.Client
+	}
+	if l.base.Client == nil {
+		if l.base.Client, err = api.NewClient(api.DefaultConfig()); err != nil {
+			return nil, fmt.Errorf("error creating client with default config: %s", err)
+		}
+	}
+	if l.base.Logger == nil && l.base.StructuredLogger == nil {
+		l.base.Logger = cfg.Logger
+		l.base.Debug = cfg.Debug
+		l.base.StructuredLogger = cfg.StructuredLogger
+	}
+
+	if cfg.ReloadInterval > 0 {
+		l.reloadInterval = cfg.ReloadInterval
+	} else {
+		l.reloadInterval = ServiceDefinitionDefaultReloadInterval
+	}
+
+	l.defs = make(map[string]*api.AgentServiceRegistration)
+	l.services = make(map[string]*ManagedService)
+	l.stop = make(chan chan struct{})
+
+	if cfg.NotificationBus != nil {
+		cfg.NotificationBus.join("", l)
+	}
+
+	return l, nil
+}
+
+// Services returns a copy of the current map of service id to ManagedService
+func (l *ServiceDefinitionLoader) Services() map[string]*ManagedService {
+	l.mu.Lock()
+	out := make(map[string]*ManagedService, len(l.services))
+	for id, ms := range l.services {
+		out[id] = ms
+	}
+	l.mu.Unlock()
+	return out
+}
+
+// Service returns the ManagedService created from the definition with the provided id, if there is one
+func (l *ServiceDefinitionLoader) Service(serviceID string) (*ManagedService, bool) {
+	l.mu.Lock()
+	ms, ok := l.services[serviceID]
+	l.mu.Unlock()
+	return ms, ok
+}
+
+// Load reads the definition files and, if their contents have changed since the last successful load, brings the set
+// of managed services in line with them.  A file that cannot be read or parsed results in no changes being made.
+func (l *ServiceDefinitionLoader) Load() error {
+	l.loadMu.Lock()
+	defer l.loadMu.Unlock()
+
+	l.mu.Lock()
+	shutdowned := l.shutdowned
+	l.mu.Unlock()
+
+	if shutdowned {
+		return errors.New("service definition loader is shutdowned")
+	}
+
+	return l.load()
+}
+
+// Run performs an initial load of the definition files, returning any error seen, and then begins checking them for
+// change on the configured interval.
+func (l *ServiceDefinitionLoader) Run() error {
+	l.loadMu.Lock()
+	defer l.loadMu.Unlock()
+
+	l.mu.Lock()
+	shutdowned, running := l.shutdowned, l.running
+	l.mu.Unlock()
+
+	if shutdowned {
+		return errors.New("service definition loader is shutdowned")
+	}
+	if running {
+		l.log.Debug("Run() called but we're already running")
+		return nil
+	}
+
+	if err := l.load(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Shutdown may have been called while loading
+	if l.shutdowned {
+		return errors.New("service definition loader is shutdowned")
+	}
+
+	l.running = true
+
+	go l.maintain()
+
+	return nil
+}
+
+// Shutdown stops checking for change and shuts down all managed services.  Once shutdown, the loader is considered
+// defunct.
+func (l *ServiceDefinitionLoader) Shutdown() error {
+	var errs []error
+
+	l.mu.Lock()
+	if l.shutdowned {
+		l.mu.Unlock()
+		return nil
+	}
+	l.shutdowned = true
+	running := l.running
+	l.running = false
+	l.mu.Unlock()
+
+	if running {
+		drop := make(chan struct{})
+		l.stop <- drop
+		<-drop
+	}
+
+	// wait for any in-flight load to complete
+	l.loadMu.Lock()
+	defer l.loadMu.Unlock()
+
+	l.mu.Lock()
+	services := l.services
+	l.services = make(map[string]*ManagedService)
+	l.defs = make(map[string]*api.AgentServiceRegistration)
+	l.mu.Unlock()
+
+	for id, ms := range services {
+		if err := ms.Shutdown(); err != nil {
+			errs = append(errs, fmt.Errorf("error shutting down service %q: %w", id, err))
+		}
+	}
+
+	l.DetachAllNotificationRecipients(true)
+
+	return errors.Join(errs...)
+}
+
+// load performs the actual work of Load
+//
+// caller must hold load lock
+func (l *ServiceDefinitionLoader) load() error {
+	var (
+		regs []*ManagedAgentServiceRegistration
+		up   = ServiceDefinitionLoaderUpdate{Paths: l.paths}
+		buf  = new(bytes.Buffer)
+	)
+
+	// read everything first so that a change can be detected before parsing
+	contents := make([][]byte, len(l.paths))
+	for i, path := range l.paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			up.Error = fmt.Errorf("error reading service definition file %q: %w", path, err)
+			l.log.Error("Error loading service definitions", LogKeyError, up.Error)
+			l.sendNotification(NotificationSourceServiceDefinitionLoader, NotificationEventServiceDefinitionLoaderLoaded, up)
+			return up.Error
+		}
+		contents[i] = b
+		buf.WriteString(path)
+		buf.WriteByte(0)
+		buf.Write(b)
+		buf.WriteByte(0)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	if l.hash != nil && bytes.Equal(l.hash, sum[:]) {
+		l.log.Debug("Service definition files unchanged")
+		return nil
+	}
+	if l.failedHash != nil && bytes.Equal(l.failedHash, sum[:]) {
+		l.log.Debug("Service definition files unchanged since last failing to parse")
+		return l.failedErr
+	}
+
+	defs := make(map[string]*api.AgentServiceRegistration)
+	for i, path := range l.paths {
+		parsed, err := ParseServiceDefinitions(contents[i])
+		if err != nil {
+			return l.parseFailed(sum[:], up, fmt.Errorf("error parsing service definition file %q: %w", path, err))
+		}
+		regs = append(regs, parsed...)
+		for _, reg := range parsed {
+			if _, ok := defs[reg.ID]; ok {
+				return l.parseFailed(sum[:], up, fmt.Errorf("service id %q is defined more than once", reg.ID))
+			}
+			// store a copy of the definition as parsed, as Create modifies the builder
+			defs[reg.ID] = cloneServiceRegistration(&reg.AgentServiceRegistration)
+		}
+	}
+
+	l.failedHash = nil
+	l.failedErr = nil
+
+	var (
+		errs    []error
+		updated = make(map[string]struct{})
+	)
+
+	// as loads are serialized by the load lock, loader state may be read without holding lock.  it is only modified
+	// while holding lock, and never while making calls to services.
+
+	// update, or shut down, services whose definitions were removed or changed
+	for id, prev := range l.defs {
+		def, ok := defs[id]
+		if ok && reflect.DeepEqual(prev, def) {
+			continue
+		}
+
+		ms, managed := l.services[id]
+
+		if ok && managed && serviceDefinitionUpdatableInPlace(prev, def) {
+			if err := l.updateInPlace(ms, prev, def); err != nil {
+				// the previous definition is retained so that the update is re-attempted on the next load
+				errs = append(errs, fmt.Errorf("error updating service %q: %w", id, err))
+				continue
+			}
+			l.mu.Lock()
+			l.defs[id] = def
+			l.mu.Unlock()
+			updated[id] = struct{}{}
+			up.Updated = append(up.Updated, id)
+			continue
+		}
+
+		if managed {
+			if err := ms.Shutdown(); err != nil {
+				errs = append(errs, fmt.Errorf("error shutting down service %q: %w", id, err))
+			}
+		}
+
+		l.mu.Lock()
+		delete(l.services, id)
+		delete(l.defs, id)
+		l.mu.Unlock()
+
+		if ok {
+			updated[id] = struct{}{}
+			up.Updated = append(up.Updated, id)
+		} else {
+			up.Removed = append(up.Removed, id)
+		}
+	}
+
+	// create services for new or re-created definitions
+	for _, reg := range regs {
+		id := reg.ID
+		if _, ok := l.defs[id]; ok {
+			continue
+		}
+
+		cfg := l.base
+		cfg.ID = id
+		cfg.BaseChecks = nil
+		cfg.SidecarService = nil
+
+		ms, err := reg.Create(&cfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error creating service %q: %w", id, err))
+			continue
+		}
+
+		l.mu.Lock()
+		l.defs[id] = defs[id]
+		l.services[id] = ms
+		l.mu.Unlock()
+
+		if _, ok := updated[id]; !ok {
+			up.Added = append(up.Added, id)
+		}
+	}
+
+	sort.Strings(up.Added)
+	sort.Strings(up.Updated)
+	sort.Strings(up.Removed)
+
+	up.Error = errors.Join(errs...)
+
+	// only record the hash if everything was successful, ensuring failed definitions are re-attempted
+	if up.Error == nil {
+		l.hash = sum[:]
+		l.log.Debug("Service definitions loaded", "added", up.Added, "updated", up.Updated, "removed", up.Removed)
+	} else {
+		l.hash = nil
+		l.log.Error("Error loading service definitions", LogKeyError, up.Error)
+	}
+
+	l.sendNotification(NotificationSourceServiceDefinitionLoader, NotificationEventServiceDefinitionLoaderLoaded, up)
+
+	return up.Error
+}
+
+// parseFailed records the hash of contents that could not be parsed so that they are not parsed again until changed,
+// then reports err
+//
+// caller must hold load lock
+func (l *ServiceDefinitionLoader) parseFailed(sum []byte, up ServiceDefinitionLoaderUpdate, err error) error {
+	l.failedHash = sum
+	l.failedErr = err
+	up.Error = err
+	l.log.Error("Error loading service definitions", LogKeyError, err)
+	l.sendNotification(NotificationSourceServiceDefinitionLoader, NotificationEventServiceDefinitionLoaderLoaded, up)
+	return err
+}
+
+// updateInPlace brings the provided running service in line with its changed definition without re-registering it.
+// The address, port, meta, and weights of the definition are enforced as the service's desired state, and its tags
+// are replaced.
+func (l *ServiceDefinitionLoader) updateInPlace(ms *ManagedService, prev, def *api.AgentServiceRegistration) error {
+	l.log.Debug("Updating service in place", LogKeyServiceID, def.ID)
+
+	err := ms.updateDesiredState(func(cur *ManagedServiceDesiredState) *ManagedServiceDesiredState {
+		cur.Address = def.Address
+		cur.Port = def.Port
+		if def.Meta != nil || prev.Meta != nil {
+			cur.Meta = make(map[string]string, len(def.Meta))
+			for k, v := range def.Meta {
+				cur.Meta[k] = v
+			}
+		}
+		if def.Weights != nil {
+			cur.Weights = new(api.AgentWeights)
+			*cur.Weights = *def.Weights
+		}
+		return cur
+	})
+	if err != nil {
+		return fmt.Errorf("error applying desired state: %w", err)
+	}
+
+	if !strSlicesEqual(prev.Tags, def.Tags) {
+		tags := make([]string, len(def.Tags))
+		copy(tags, def.Tags)
+		if _, err = ms.MutateTags(func([]string) []string { return tags }); err != nil {
+			return fmt.Errorf("error replacing tags: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (l *ServiceDefinitionLoader) maintain() {
+	ticker := time.NewTicker(l.reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// load logs and reports its own errors
+			l.loadMu.Lock()
+			_ = l.load()
+			l.loadMu.Unlock()
+
+		case drop := <-l.stop:
+			l.log.Info("Stop hit")
+			close(drop)
+			return
+		}
+	}
+}
+
+// serviceDefinitionUpdatableInPlace returns true if the only differences between the two definitions are ones that
+// may be applied to a running ManagedService without re-registering it: its address, port, meta, weights, and tags.
+// Weights may not be removed in place, as an unset desired weight is not enforced.
+func serviceDefinitionUpdatableInPlace(prev, def *api.AgentServiceRegistration) bool {
+	if prev.Weights != nil && def.Weights == nil {
+		return false
+	}
+	a, b := *prev, *def
+	for _, r := range []*api.AgentServiceRegistration{&a, &b} {
+		r.Address = ""
+		r.Port = 0
+		r.Meta = nil
+		r.Weights = nil
+		r.Tags = nil
+	}
+	return reflect.DeepEqual(&a, &b)
+}
+
+// decodeServiceDefinition decodes a single raw service definition into the provided registration
+func decodeServiceDefinition(raw map[string]interface{}, reg *api.AgentServiceRegistration) error {
+	norm, err := normalizeServiceDefinition(raw, false)
+	if err != nil {
+		return err
+	}
+
+	aliasServiceDefinitionCheckIDs(norm)
+
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		ErrorUnused:      true,
+		WeaklyTypedInput: true,
+		Result:           reg,
+	})
+	if err != nil {
+		return err
+	}
+
+	return dec.Decode(norm)
+}
+
+// normalizeServiceDefinitionKey strips underscores from and lowercases the provided key.  mapstructure matches keys
+// case-insensitively against field names, so this allows snake_case keys to be decoded directly into api types.
+func normalizeServiceDefinitionKey(k string) string {
+	return strings.ToLower(strings.ReplaceAll(k, "_", ""))
+}
+
+// flattenServiceDefinitionBlock returns the single object within val if it is a list of objects, as HCL decodes blocks
+func flattenServiceDefinitionBlock(k string, val interface{}) (interface{}, error) {
+	if list, ok := val.([]map[string]interface{}); ok {
+		if len(list) != 1 {
+			return nil, fmt.Errorf("only one %q block may be defined, saw %d", k, len(list))
+		}
+		return list[0], nil
+	}
+	return val, nil
+}
+
+// normalizeServiceDefinition flattens single-object blocks and normalizes keys so that the definition may be decoded
+// directly into api types.  If opaque is true, keys are left as-is.
+func normalizeServiceDefinition(v interface{}, opaque bool) (interface{}, error) {
+	var err error
+
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(tv))
+		for k, val := range tv {
+			nk := normalizeServiceDefinitionKey(k)
+
+			if !opaque {
+				if _, ok := serviceDefinitionBlockKeys[nk]; ok {
+					if val, err = flattenServiceDefinitionBlock(k, val); err != nil {
+						return nil, err
+					}
+				}
+
+				// tagged addresses are user-keyed, but each value is itself a block
+				if m, ok := val.(map[string]interface{}); ok && nk == "taggedaddresses" {
+					for tk, tval := range m {
+						if m[tk], err = flattenServiceDefinitionBlock(tk, tval); err != nil {
+							return nil, err
+						}
+					}
+				}
+			}
+
+			_, isOpaque := serviceDefinitionOpaqueKeys[nk]
+			nv, err := normalizeServiceDefinition(val, opaque || isOpaque)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+
+			if opaque {
+				out[k] = nv
+			} else {
+				out[nk] = nv
+			}
+		}
+		return out, nil
+
+	case []map[string]interface{}:
+		out := make([]interface{}, len(tv))
+		for i, m := range tv {
+			nv, err := normalizeServiceDefinition(m, opaque)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = nv
+		}
+		return out, nil
+
+	case []interface{}:
+		out := make([]interface{}, len(tv))
+		for i, val := range tv {
+			nv, err := normalizeServiceDefinition(val, opaque)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = nv
+		}
+		return out, nil
+
+	default:
+		return v, nil
+	}
+}
+
+// aliasServiceDefinitionCheckIDs renames the "id" key of each check within a normalized service definition, including
+// those of its sidecar, to "checkid" as is accepted in agent configuration files
+func aliasServiceDefinitionCheckIDs(svc interface{}) {
+	m, ok := svc.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	alias := func(check interface{}) {
+		if cm, ok := check.(map[string]interface{}); ok {
+			if id, ok := cm["id"]; ok {
+				if _, ok := cm["checkid"]; !ok {
+					cm["checkid"] = id
+				}
+				delete(cm, "id")
+			}
+		}
+	}
+
+	alias(m["check"])
+	if checks, ok := m["checks"].([]interface{}); ok {
+		for _, check := range checks {
+			alias(check)
+		}
+	}
+
+	if connect, ok := m["connect"].(map[string]interface{}); ok {
+		aliasServiceDefinitionCheckIDs(connect["sidecarservice"])
+	}
+}
+
+// validateServiceDefinition validates the provided registration in the same way as a SimpleServiceRegistration,
+// filling in the address and id if they were not defined.  localAddrErr is the error seen determining localAddr, if any.
+func validateServiceDefinition(localAddr string, localAddrErr error, localHostname string, reg *api.AgentServiceRegistration) error {
+	name, err := validateServiceNameAndPort(reg.Name, reg.Port)
+	if err != nil {
+		return err
+	}
+	reg.Name = name
+	if reg.Address == "" {
+		if localAddrErr != nil {
+			return fmt.Errorf("address not defined and local address could not be determined: %w", localAddrErr)
+		}
+		reg.Address = localAddr
+	}
+	if reg.ID == "" {
+		reg.ID = defaultSimpleServiceID(reg.Name, localHostname, false)
+	}
+	return nil
+}
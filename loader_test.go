@@ -0,0 +1,307 @@
+package consultant_test
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/myENA/consultant/v2"
+)
+
+const (
+	loaderHCLDefinitions = `
+service {
+  id   = "web-1"
+  name = "web"
+  port = 8080
+  tags = ["a", "b"]
+  meta {
+    build_id = "1234"
+  }
+  enable_tag_override = true
+
+  check {
+    id       = "web-http"
+    http     = "http://127.0.0.1:8080/health"
+    interval = "10s"
+    deregister_critical_service_after = "1m"
+  }
+
+  checks = [
+    {
+      tcp      = "127.0.0.1:8080"
+      interval = "5s"
+    },
+  ]
+
+  connect {
+    sidecar_service {
+      port = 21000
+      proxy {
+        upstreams = [
+          {
+            destination_name = "db"
+            local_bind_port  = 9191
+          },
+        ]
+      }
+    }
+  }
+}
+
+service {
+  name = "api"
+  port = 8081
+}
+`
+
+	loaderJSONDefinitions = `{
+  "services": [
+    {
+      "ID": "web-1",
+      "Name": "web",
+      "Port": 8080,
+      "Weights": {"Passing": 10, "Warning": 1},
+      "Checks": [{"ttl": "30s", "status": "passing"}]
+    }
+  ]
+}`
+)
+
+func TestParseServiceDefinitions(t *testing.T) {
+	// definitions without an address use the local address, which may not be determinable in all environments
+	t.Setenv(consultant.EnvConsulLocalAddr, "127.0.0.1")
+
+	t.Run("hcl", func(t *testing.T) {
+		regs, err := consultant.ParseServiceDefinitions([]byte(loaderHCLDefinitions))
+		if err != nil {
+			t.Fatalf("Error parsing definitions: %s", err)
+		}
+		if len(regs) != 2 {
+			t.Fatalf("Expected 2 definitions, saw %d", len(regs))
+		}
+
+		web := regs[0]
+		if web.ID != "web-1" || web.Name != "web" || web.Port != 8080 || !web.EnableTagOverride {
+			t.Logf("Unexpected service fields: %+v", web.AgentServiceRegistration)
+			t.Fail()
+		}
+		if len(web.Tags) != 2 || web.Meta["build_id"] != "1234" {
+			t.Logf("Unexpected tags or meta: %v %v", web.Tags, web.Meta)
+			t.Fail()
+		}
+		if web.Check == nil || web.Check.CheckID != "web-http" || web.Check.DeregisterCriticalServiceAfter != "1m" {
+			t.Logf("Unexpected check: %+v", web.Check)
+			t.Fail()
+		}
+		if len(web.Checks) != 1 || web.Checks[0].TCP != "127.0.0.1:8080" {
+			t.Logf("Unexpected checks: %+v", web.Checks)
+			t.Fail()
+		}
+		if web.Connect == nil || web.Connect.SidecarService == nil || web.Connect.SidecarService.Port != 21000 {
+			t.Fatalf("Unexpected connect: %+v", web.Connect)
+		}
+		if p := web.Connect.SidecarService.Proxy; p == nil || len(p.Upstreams) != 1 || p.Upstreams[0].LocalBindPort != 9191 {
+			t.Logf("Unexpected sidecar proxy: %+v", p)
+			t.Fail()
+		}
+
+		if regs[1].ID == "" {
+			t.Log("Expected id to be defaulted")
+			t.Fail()
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		regs, err := consultant.ParseServiceDefinitions([]byte(loaderJSONDefinitions))
+		if err != nil {
+			t.Fatalf("Error parsing definitions: %s", err)
+		}
+		if len(regs) != 1 {
+			t.Fatalf("Expected 1 definition, saw %d", len(regs))
+		}
+		if w := regs[0].Weights; w == nil || w.Passing != 10 || w.Warning != 1 {
+			t.Logf("Unexpected weights: %+v", w)
+			t.Fail()
+		}
+		if len(regs[0].Checks) != 1 || regs[0].Checks[0].TTL != "30s" {
+			t.Logf("Unexpected checks: %+v", regs[0].Checks)
+			t.Fail()
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for name, def := range map[string]string{
+			"blank-name":    `service { port = 80 }`,
+			"space-in-name": `service { name = "no good" port = 80 }`,
+			"bad-port":      `service { name = "web" }`,
+			"unknown-field": `service { name = "web" port = 80 sandwich = true }`,
+			"unknown-key":   `sandwich { name = "web" }`,
+			"duplicate-id":  `service { id = "a" name = "web" port = 80 } service { id = "a" name = "api" port = 81 }`,
+		} {
+			if _, err := consultant.ParseServiceDefinitions([]byte(def)); err == nil {
+				t.Logf("Expected error parsing %q definition", name)
+				t.Fail()
+			}
+		}
+	})
+
+	t.Run("no-local-address", func(t *testing.T) {
+		t.Setenv(consultant.EnvConsulLocalAddr, "")
+		t.Setenv(consultant.EnvConsulLocalInterface, "consultant-no-such-interface")
+
+		if _, err := consultant.ParseServiceDefinitions([]byte(`service { name = "web" port = 80 }`)); err == nil {
+			t.Log("Expected error parsing definition without address when local address cannot be determined")
+			t.Fail()
+		}
+		if _, err := consultant.ParseServiceDefinitions([]byte(`service { name = "web" address = "10.0.0.1" port = 80 }`)); err != nil {
+			t.Logf("Expected definition with address to parse, saw: %s", err)
+			t.Fail()
+		}
+	})
+}
+
+func TestServiceDefinitionLoader(t *testing.T) {
+	server, client := makeTestServerAndClient(t, nil)
+	defer stopTestServer(server)
+	server.WaitForSerfCheck(t)
+
+	path := filepath.Join(t.TempDir(), "services.hcl")
+	write := func(t *testing.T, def string) {
+		if err := os.WriteFile(path, []byte(def), 0600); err != nil {
+			t.Fatalf("Error writing definition file: %s", err)
+		}
+	}
+
+	write(t, `service { id = "loaded-1" name = "loaded" port = 1500 }`)
+
+	l, err := consultant.NewServiceDefinitionLoader(&consultant.ServiceDefinitionLoaderConfig{
+		Paths:  []string{path},
+		Client: client.Client,
+		Logger: log.New(os.Stdout, "---> service definition loader ", log.LstdFlags),
+		Debug:  true,
+	})
+	if err != nil {
+		t.Fatalf("Error creating loader: %s", err)
+	}
+	defer func() { _ = l.Shutdown() }()
+
+	if err := l.Run(); err != nil {
+		t.Fatalf("Error running loader: %s", err)
+	}
+
+	if _, ok := l.Service("loaded-1"); !ok {
+		t.Fatal("Expected service loaded-1 to be managed")
+	}
+
+	t.Run("reload", func(t *testing.T) {
+		write(t, `service { id = "loaded-2" name = "loaded" port = 1501 }`)
+		if err := l.Load(); err != nil {
+			t.Fatalf("Error reloading definitions: %s", err)
+		}
+
+		if _, ok := l.Service("loaded-1"); ok {
+			t.Log("Expected service loaded-1 to have been removed")
+			t.Fail()
+		}
+		if _, ok := l.Service("loaded-2"); !ok {
+			t.Log("Expected service loaded-2 to be managed")
+			t.Fail()
+		}
+
+		svcs, err := client.Agent().Services()
+		if err != nil {
+			t.Fatalf("Error fetching services: %s", err)
+		}
+		if _, ok := svcs["loaded-1"]; ok {
+			t.Log("Expected service loaded-1 to have been deregistered")
+			t.Fail()
+		}
+		if _, ok := svcs["loaded-2"]; !ok {
+			t.Log("Expected service loaded-2 to have been registered")
+			t.Fail()
+		}
+	})
+
+	t.Run("in-place", func(t *testing.T) {
+		before, ok := l.Service("loaded-2")
+		if !ok {
+			t.Fatal("Expected service loaded-2 to be managed")
+		}
+
+		write(t, `service { id = "loaded-2" name = "loaded" port = 1502 tags = ["changed"] }`)
+		if err := l.Load(); err != nil {
+			t.Fatalf("Error reloading definitions: %s", err)
+		}
+
+		after, ok := l.Service("loaded-2")
+		if !ok {
+			t.Fatal("Expected service loaded-2 to still be managed")
+		}
+		if before != after {
+			t.Log("Expected service loaded-2 to have been updated in place rather than re-created")
+			t.Fail()
+		}
+
+		svc, _, err := client.Agent().Service("loaded-2", nil)
+		if err != nil {
+			t.Fatalf("Error fetching service: %s", err)
+		}
+		if svc.Port != 1502 {
+			t.Logf("Expected port 1502, saw %d", svc.Port)
+			t.Fail()
+		}
+		if len(svc.Tags) != 1 || svc.Tags[0] != "changed" {
+			t.Logf("Expected tags to be replaced, saw %v", svc.Tags)
+			t.Fail()
+		}
+	})
+
+	t.Run("invalid-reload", func(t *testing.T) {
+		write(t, `service { name = "loaded" }`)
+		if err := l.Load(); err == nil {
+			t.Log("Expected error loading invalid definitions")
+			t.Fail()
+		}
+		if _, ok := l.Service("loaded-2"); !ok {
+			t.Log("Expected service loaded-2 to still be managed after failed reload")
+			t.Fail()
+		}
+	})
+}
+
+func TestServiceDefinitionLoader_ParseFailure(t *testing.T) {
+	var loads uint64
+
+	path := filepath.Join(t.TempDir(), "services.hcl")
+	if err := os.WriteFile(path, []byte(`service { name = "loaded" }`), 0600); err != nil {
+		t.Fatalf("Error writing definition file: %s", err)
+	}
+
+	l, err := consultant.NewServiceDefinitionLoader(&consultant.ServiceDefinitionLoaderConfig{Paths: []string{path}})
+	if err != nil {
+		t.Fatalf("Error creating loader: %s", err)
+	}
+	defer func() { _ = l.Shutdown() }()
+
+	l.AttachNotificationHandler(
+		"",
+		func(consultant.Notification) { atomic.AddUint64(&loads, 1) },
+		consultant.WithNotificationSync(time.Second, consultant.NotificationEventServiceDefinitionLoaderLoaded),
+	)
+
+	for i := 0; i < 3; i++ {
+		if err := l.Load(); err == nil {
+			t.Logf("Expected load %d to fail", i)
+			t.Fail()
+		}
+	}
+
+	if v := atomic.LoadUint64(&loads); v != 1 {
+		t.Logf("Expected unchanged failing contents to be reported once, saw %d", v)
+		t.Fail()
+	}
+}
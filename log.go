@@ -0,0 +1,172 @@
+package consultant
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// Keys used by managed types when logging
+const (
+	LogKeyComponent   = "component"
+	LogKeySessionID   = "session_id"
+	LogKeySessionName = "session_name"
+	LogKeyCandidateID = "candidate_id"
+	LogKeyServiceID   = "service_id"
+	LogKeyEvent       = "event"
+	LogKeyError       = "error"
+)
+
+// Values of the LogKeyComponent field
+const (
+	LogComponentManagedSession          = "managed-session"
+	LogComponentCandidate               = "candidate"
+	LogComponentManagedService          = "managed-service"
+	LogComponentServiceSupervisor       = "service-supervisor"
+	LogComponentServiceDefinitionLoader = "service-definition-loader"
+	LogComponentNotificationBus         = "notification-bus"
+	LogComponentNotificationSink        = "notification-sink"
+)
+
+// StructuredLogger is a levelled logger accepting alternating key / value pairs after each message.  Managed types
+// attach the LogKeyComponent field, along with whichever of the other LogKey fields are known at the time, to every
+// message.
+//
+// Adapters are provided for log/slog (NewSlogLogger), hclog (NewHCLogLogger), and the Printf-only Logger
+// (NewPrintfLogger).
+type StructuredLogger interface {
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Warn(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+
+	// With returns a logger that includes the provided key / value pairs with every message
+	With(kv ...interface{}) StructuredLogger
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger returns a StructuredLogger writing to the provided slog.Logger.  If l is nil, slog.Default() is used.
+func NewSlogLogger(l *slog.Logger) StructuredLogger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &slogLogger{l: l}
+}
+
+func (sl *slogLogger) Debug(msg string, kv ...interface{}) { sl.l.Debug(msg, kv...) }
+func (sl *slogLogger) Info(msg string, kv ...interface{})  { sl.l.Info(msg, kv...) }
+func (sl *slogLogger) Warn(msg string, kv ...interface{})  { sl.l.Warn(msg, kv...) }
+func (sl *slogLogger) Error(msg string, kv ...interface{}) { sl.l.Error(msg, kv...) }
+
+func (sl *slogLogger) With(kv ...interface{}) StructuredLogger {
+	return &slogLogger{l: sl.l.With(kv...)}
+}
+
+type hclogLogger struct {
+	l hclog.Logger
+}
+
+// NewHCLogLogger returns a StructuredLogger writing to the provided hclog.Logger.  If l is nil, hclog.Default() is
+// used.
+func NewHCLogLogger(l hclog.Logger) StructuredLogger {
+	if l == nil {
+		l = hclog.Default()
+	}
+	return &hclogLogger{l: l}
+}
+
+func (hl *hclogLogger) Debug(msg string, kv ...interface{}) { hl.l.Debug(msg, kv...) }
+func (hl *hclogLogger) Info(msg string, kv ...interface{})  { hl.l.Info(msg, kv...) }
+func (hl *hclogLogger) Warn(msg string, kv ...interface{})  { hl.l.Warn(msg, kv...) }
+func (hl *hclogLogger) Error(msg string, kv ...interface{}) { hl.l.Error(msg, kv...) }
+
+func (hl *hclogLogger) With(kv ...interface{}) StructuredLogger {
+	return &hclogLogger{l: hl.l.With(kv...)}
+}
+
+type printfLogger struct {
+	l   Logger
+	dbg bool
+	kv  []interface{}
+}
+
+// NewPrintfLogger returns a StructuredLogger writing to the provided Printf-only Logger.  Each message is written as a
+// single line prefixed with its level, followed by its key / value pairs in logfmt style.  Debug messages are discarded
+// unless debug is true.  If l is nil, all messages are discarded.
+func NewPrintfLogger(l Logger, debug bool) StructuredLogger {
+	if l == nil {
+		return discardLogger{}
+	}
+	return &printfLogger{l: l, dbg: debug}
+}
+
+func (pl *printfLogger) Debug(msg string, kv ...interface{}) {
+	if pl.dbg {
+		pl.write("DEBUG", msg, kv)
+	}
+}
+
+func (pl *printfLogger) Info(msg string, kv ...interface{})  { pl.write("INFO", msg, kv) }
+func (pl *printfLogger) Warn(msg string, kv ...interface{})  { pl.write("WARN", msg, kv) }
+func (pl *printfLogger) Error(msg string, kv ...interface{}) { pl.write("ERROR", msg, kv) }
+
+func (pl *printfLogger) With(kv ...interface{}) StructuredLogger {
+	npl := &printfLogger{l: pl.l, dbg: pl.dbg}
+	npl.kv = make([]interface{}, 0, len(pl.kv)+len(kv))
+	npl.kv = append(npl.kv, pl.kv...)
+	npl.kv = append(npl.kv, kv...)
+	return npl
+}
+
+func (pl *printfLogger) write(level, msg string, kv []interface{}) {
+	var sb strings.Builder
+	sb.WriteString("[")
+	sb.WriteString(level)
+	sb.WriteString("] ")
+	sb.WriteString(msg)
+	writeLogFields(&sb, pl.kv)
+	writeLogFields(&sb, kv)
+	pl.l.Printf("%s", sb.String())
+}
+
+// writeLogFields appends kv to sb as space-separated key=value pairs.  A trailing key without a value is written with
+// a value of "MISSING".
+func writeLogFields(sb *strings.Builder, kv []interface{}) {
+	for i := 0; i < len(kv); i += 2 {
+		sb.WriteString(" ")
+		sb.WriteString(fmt.Sprint(kv[i]))
+		sb.WriteString("=")
+		if i+1 == len(kv) {
+			sb.WriteString("MISSING")
+			break
+		}
+		v := fmt.Sprint(kv[i+1])
+		if v == "" || strings.ContainsAny(v, " =\"\t\n") {
+			v = strconv.Quote(v)
+		}
+		sb.WriteString(v)
+	}
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Debug(string, ...interface{})            {}
+func (discardLogger) Info(string, ...interface{})             {}
+func (discardLogger) Warn(string, ...interface{})             {}
+func (discardLogger) Error(string, ...interface{})            {}
+func (dl discardLogger) With(...interface{}) StructuredLogger { return dl }
+
+// buildStructuredLogger returns the logger a managed type should use given its configuration.  sl is preferred,
+// falling back to a shim around l.  If neither is defined, all messages are discarded.
+func buildStructuredLogger(sl StructuredLogger, l Logger, debug bool, kv ...interface{}) StructuredLogger {
+	if sl == nil {
+		sl = NewPrintfLogger(l, debug)
+	}
+	return sl.With(kv...)
+}
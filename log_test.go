@@ -0,0 +1,114 @@
+package consultant_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/myENA/consultant/v2"
+)
+
+type testPrintfLogger struct {
+	lines []string
+}
+
+func (l *testPrintfLogger) Printf(f string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(f, v...))
+}
+
+func TestNewPrintfLogger(t *testing.T) {
+	t.Run("fields", func(t *testing.T) {
+		pl := new(testPrintfLogger)
+		sl := consultant.NewPrintfLogger(pl, false).With(consultant.LogKeyComponent, consultant.LogComponentCandidate)
+
+		sl.Debug("hidden")
+		sl.Info("hello", consultant.LogKeyCandidateID, "cand-1")
+		sl.Error("oops", consultant.LogKeyError, errors.New("it broke"), "dangling")
+
+		if len(pl.lines) != 2 {
+			t.Fatalf("Expected 2 lines to be written with debug disabled, saw %d: %v", len(pl.lines), pl.lines)
+		}
+		if expected := "[INFO] hello component=candidate candidate_id=cand-1"; pl.lines[0] != expected {
+			t.Logf("Expected %q, saw %q", expected, pl.lines[0])
+			t.Fail()
+		}
+		if expected := `[ERROR] oops component=candidate error="it broke" dangling=MISSING`; pl.lines[1] != expected {
+			t.Logf("Expected %q, saw %q", expected, pl.lines[1])
+			t.Fail()
+		}
+	})
+
+	t.Run("debug", func(t *testing.T) {
+		pl := new(testPrintfLogger)
+		sl := consultant.NewPrintfLogger(pl, true)
+
+		sl.Debug("shown", "empty", "")
+		if len(pl.lines) != 1 || pl.lines[0] != `[DEBUG] shown empty=""` {
+			t.Logf("Expected debug line to be written, saw %v", pl.lines)
+			t.Fail()
+		}
+	})
+
+	t.Run("with-isolated", func(t *testing.T) {
+		pl := new(testPrintfLogger)
+		base := consultant.NewPrintfLogger(pl, false).With("a", 1)
+		_ = base.With("b", 2)
+
+		base.Info("msg")
+		if len(pl.lines) != 1 || pl.lines[0] != "[INFO] msg a=1" {
+			t.Logf("Expected child logger fields to not leak into parent, saw %v", pl.lines)
+			t.Fail()
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		sl := consultant.NewPrintfLogger(nil, true)
+		sl.With("a", 1).Error("discarded")
+	})
+}
+
+func TestNewSlogLogger(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	sl := consultant.NewSlogLogger(l).With(consultant.LogKeyComponent, consultant.LogComponentManagedService)
+
+	sl.Debug("hidden")
+	sl.Warn("careful", consultant.LogKeyServiceID, "svc-1")
+
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Logf("Expected debug message to be filtered by handler level, saw %q", out)
+		t.Fail()
+	}
+	for _, expected := range []string{"level=WARN", "msg=careful", "component=managed-service", "service_id=svc-1"} {
+		if !strings.Contains(out, expected) {
+			t.Logf("Expected output to contain %q, saw %q", expected, out)
+			t.Fail()
+		}
+	}
+}
+
+func TestNewHCLogLogger(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := hclog.New(&hclog.LoggerOptions{Output: buf, Level: hclog.Info})
+	sl := consultant.NewHCLogLogger(l).With(consultant.LogKeyComponent, consultant.LogComponentManagedSession)
+
+	sl.Debug("hidden")
+	sl.Error("failed", consultant.LogKeySessionID, "sess-1")
+
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Logf("Expected debug message to be filtered by logger level, saw %q", out)
+		t.Fail()
+	}
+	for _, expected := range []string{"[ERROR]", "failed", "component=managed-session", "session_id=sess-1"} {
+		if !strings.Contains(out, expected) {
+			t.Logf("Expected output to contain %q, saw %q", expected, out)
+			t.Fail()
+		}
+	}
+}
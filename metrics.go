@@ -0,0 +1,26 @@
+package consultant
+
+import (
+	"time"
+)
+
+// MetricsRecorder may be provided to a managed type's config to have it record measurements of its internal
+// operations.  Implementations must be safe for concurrent use, and must not block.  A Prometheus implementation is
+// provided by the prommetrics package.
+type MetricsRecorder interface {
+	// SessionRenewed is called after each attempt to renew an upstream session
+	SessionRenewed(sessionName string, took time.Duration, err error)
+
+	// CandidateElectionChanged is called whenever a candidate wins or loses an election.  When elected is false,
+	// leaderFor is the amount of time the candidate was leader.
+	CandidateElectionChanged(candidateID string, elected bool, leaderFor time.Duration)
+
+	// ServiceRefreshed is called after each attempt to refresh the local state of a managed service
+	ServiceRefreshed(serviceID string, err error)
+
+	// ServiceReRegistered is called after each attempt to re-register a managed service that was found missing
+	ServiceReRegistered(serviceID string, err error)
+
+	// NotificationDropped is called whenever a notification is dropped for a recipient due to backpressure
+	NotificationDropped(n Notification)
+}
@@ -1,8 +1,13 @@
 package consultant
 
 import (
+	"context"
 	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,6 +18,8 @@ const (
 	NotificationSourceManagedSession NotificationSource = iota
 	NotificationSourceCandidate
 	NotificationSourceManagedService
+	NotificationSourceServiceSupervisor
+	NotificationSourceServiceDefinitionLoader
 
 	NotificationSourceTest NotificationSource = 0xf
 )
@@ -25,6 +32,10 @@ func (ns NotificationSource) String() string {
 		return "Candidate"
 	case NotificationSourceManagedService:
 		return "ManagedService"
+	case NotificationSourceServiceSupervisor:
+		return "ServiceSupervisor"
+	case NotificationSourceServiceDefinitionLoader:
+		return "ServiceDefinitionLoader"
 
 	case NotificationSourceTest:
 		return "Test"
@@ -73,6 +84,35 @@ const (
 	NotificationEventManagedServiceTagsAdded        NotificationEvent = 0x186 // sent when an add tags attempt is made
 	NotificationEventManagedServiceTagsRemoved      NotificationEvent = 0x187 // sent when a remove tags attempt is made
 	NotificationEventManagedServiceShutdowned       NotificationEvent = 0x188 // sent when managed service has been closed and must be considered defunct
+	NotificationEventManagedServiceMaintenanceOn    NotificationEvent = 0x189 // sent when an attempt is made to place the service into maintenance mode
+	NotificationEventManagedServiceMaintenanceOff   NotificationEvent = 0x18a // sent when an attempt is made to take the service out of maintenance mode
+	NotificationEventManagedServiceDrainStarted     NotificationEvent = 0x18b // sent when an attempt is made to take the service out of rotation prior to deregistration
+	NotificationEventManagedServiceDrainFinished    NotificationEvent = 0x18c // sent once the drain grace period has elapsed or the service is no longer seen as passing
+	NotificationEventManagedServiceDrift            NotificationEvent = 0x18d // sent when an attempt is made to correct drift from the desired state
+	NotificationEventManagedServiceTagsMutated      NotificationEvent = 0x18e // sent when a tag mutation attempt is made
+	NotificationEventManagedServiceSidecarMissing   NotificationEvent = 0x18f // sent when the sidecar service was not found and an attempt was made to re-register it
+	NotificationEventManagedServiceHealthChanged    NotificationEvent = 0x190 // sent when the aggregate health of the service's checks has transitioned
+	NotificationEventManagedServiceHealingAction    NotificationEvent = 0x191 // sent when a healing rule has taken action against a critical check
+	NotificationEventManagedServiceBootstrapped     NotificationEvent = 0x192 // sent once a bootstrap registration has been seen on the agent
+	NotificationEventManagedServiceOrphanSwept      NotificationEvent = 0x193 // sent when an orphaned instance of the service has been deregistered
+	NotificationEventManagedServiceBootstrapFailed  NotificationEvent = 0x194 // sent when a bootstrap registration is rejected by the agent and will not be retried
+
+	// 512 - 639
+
+	NotificationEventServiceSupervisorRunning              NotificationEvent = 0x200 // sent when the supervisor enters running
+	NotificationEventServiceSupervisorStopped              NotificationEvent = 0x201 // sent when the supervisor leaves running
+	NotificationEventServiceSupervisorShutdowned           NotificationEvent = 0x202 // sent when the supervisor has been closed and must be considered defunct
+	NotificationEventServiceSupervisorRefreshed            NotificationEvent = 0x203 // sent after every reconciliation pass.  only successful if Error is nil.
+	NotificationEventServiceSupervisorServiceRegistered    NotificationEvent = 0x204 // sent when an attempt is made to register a supervised service
+	NotificationEventServiceSupervisorServiceDeregistered  NotificationEvent = 0x205 // sent when an attempt is made to deregister a supervised service
+	NotificationEventServiceSupervisorServiceMissing       NotificationEvent = 0x206 // sent when a supervised service was not found and an attempt was made to re-register it
+	NotificationEventServiceSupervisorServiceDrift         NotificationEvent = 0x207 // sent when a supervised service had drifted and an attempt was made to re-register it
+	NotificationEventServiceSupervisorServiceDrainStarted  NotificationEvent = 0x208 // sent when an attempt is made to place a supervised service into maintenance mode for draining
+	NotificationEventServiceSupervisorServiceDrainFinished NotificationEvent = 0x209 // sent once a supervised service's drain wait has ended
+
+	// 640 - 767
+
+	NotificationEventServiceDefinitionLoaderLoaded NotificationEvent = 0x280 // sent whenever an attempt is made to load changed service definitions.  only successful if Error is nil.
 )
 
 func (ev NotificationEvent) String() string {
@@ -101,6 +141,8 @@ func (ev NotificationEvent) String() string {
 		return "CandidateRunning"
 	case NotificationEventCandidateElected:
 		return "CandidateElected"
+	case NotificationEventCandidateLostElection:
+		return "CandidateLostElection"
 	case NotificationEventCandidateResigned:
 		return "CandidateResigned"
 	case NotificationEventCandidateRenew:
@@ -126,6 +168,54 @@ func (ev NotificationEvent) String() string {
 		return "ManagedServiceTagsRemoved"
 	case NotificationEventManagedServiceShutdowned:
 		return "ManagedServiceShutdowned"
+	case NotificationEventManagedServiceMaintenanceOn:
+		return "ManagedServiceMaintenanceOn"
+	case NotificationEventManagedServiceMaintenanceOff:
+		return "ManagedServiceMaintenanceOff"
+	case NotificationEventManagedServiceDrainStarted:
+		return "ManagedServiceDrainStarted"
+	case NotificationEventManagedServiceDrainFinished:
+		return "ManagedServiceDrainFinished"
+	case NotificationEventManagedServiceDrift:
+		return "ManagedServiceDrift"
+	case NotificationEventManagedServiceTagsMutated:
+		return "ManagedServiceTagsMutated"
+	case NotificationEventManagedServiceSidecarMissing:
+		return "ManagedServiceSidecarMissing"
+	case NotificationEventManagedServiceHealthChanged:
+		return "ManagedServiceHealthChanged"
+	case NotificationEventManagedServiceHealingAction:
+		return "ManagedServiceHealingAction"
+	case NotificationEventManagedServiceBootstrapped:
+		return "ManagedServiceBootstrapped"
+	case NotificationEventManagedServiceOrphanSwept:
+		return "ManagedServiceOrphanSwept"
+	case NotificationEventManagedServiceBootstrapFailed:
+		return "ManagedServiceBootstrapFailed"
+
+	case NotificationEventServiceSupervisorRunning:
+		return "ServiceSupervisorRunning"
+	case NotificationEventServiceSupervisorStopped:
+		return "ServiceSupervisorStopped"
+	case NotificationEventServiceSupervisorShutdowned:
+		return "ServiceSupervisorShutdowned"
+	case NotificationEventServiceSupervisorRefreshed:
+		return "ServiceSupervisorRefreshed"
+	case NotificationEventServiceSupervisorServiceRegistered:
+		return "ServiceSupervisorServiceRegistered"
+	case NotificationEventServiceSupervisorServiceDeregistered:
+		return "ServiceSupervisorServiceDeregistered"
+	case NotificationEventServiceSupervisorServiceMissing:
+		return "ServiceSupervisorServiceMissing"
+	case NotificationEventServiceSupervisorServiceDrift:
+		return "ServiceSupervisorServiceDrift"
+	case NotificationEventServiceSupervisorServiceDrainStarted:
+		return "ServiceSupervisorServiceDrainStarted"
+	case NotificationEventServiceSupervisorServiceDrainFinished:
+		return "ServiceSupervisorServiceDrainFinished"
+
+	case NotificationEventServiceDefinitionLoaderLoaded:
+		return "ServiceDefinitionLoaderLoaded"
 
 	default:
 		return "UNKNOWN"
@@ -135,11 +225,14 @@ func (ev NotificationEvent) String() string {
 // Notification describes a specific event with associated data that gets pushed to any registered recipients at the
 // time of push
 type Notification struct {
-	ID         string // random
+	ID         string // "<producer instance id>-<sequence>", where sequence increases monotonically per producer
+	CauseID    string // ID of the notification, possibly from another producer, that caused this one.  may be empty.
 	Originated int64  // unixnano timestamp of when this notification was created
 	Source     NotificationSource
 	Event      NotificationEvent
 	Data       interface{} // no attempt is made to prevent memory sharing
+	Producer   string      // identity of the producer, set when re-published by a NotificationBus
+	Sequence   uint64      // per-recipient sequence, starting at 1.  a gap means notifications were dropped.
 }
 
 // NotificationHandler can be provided to a Notifier to be called per Notification
@@ -148,6 +241,197 @@ type NotificationHandler func(Notification)
 // NotificationChannel can be provided to a Notifier to have new Notifications pushed to it
 type NotificationChannel chan Notification
 
+// NotificationFilter may be provided to a recipient to limit which notifications it receives.  It must return true
+// for notifications that should be delivered.  It is called from within the producer's goroutine, and therefore must
+// not block.
+type NotificationFilter func(Notification) bool
+
+// NotificationOverflowPolicy determines what happens to a notification when a recipient's buffer is full
+type NotificationOverflowPolicy uint8
+
+const (
+	// NotificationOverflowDropNewest drops the notification being pushed, leaving the buffer as-is.  This is the
+	// default.
+	NotificationOverflowDropNewest NotificationOverflowPolicy = iota
+
+	// NotificationOverflowDropOldest evicts the oldest buffered notification to make room for the one being pushed
+	NotificationOverflowDropOldest
+
+	// NotificationOverflowBlock blocks the producer for up to BlockTimeout waiting for room in the buffer.  Buffered
+	// notifications are never dropped while waiting on the handler.
+	NotificationOverflowBlock
+
+	// NotificationOverflowCoalesce replaces any buffered notification with the same source and event with the one
+	// being pushed, so the recipient only ever sees the latest state of each.  If no room can be made this way, the
+	// oldest notification is evicted.
+	NotificationOverflowCoalesce
+)
+
+func (p NotificationOverflowPolicy) String() string {
+	switch p {
+	case NotificationOverflowDropNewest:
+		return "drop-newest"
+	case NotificationOverflowDropOldest:
+		return "drop-oldest"
+	case NotificationOverflowBlock:
+		return "block"
+	case NotificationOverflowCoalesce:
+		return "coalesce"
+
+	default:
+		return "UNKNOWN"
+	}
+}
+
+const (
+	NotificationDefaultBufferSize   = 100
+	NotificationDefaultBlockTimeout = 5 * time.Second
+	NotificationDefaultSyncTimeout  = 5 * time.Second
+)
+
+// NotificationRecipientConfig describes how notifications are delivered to a single recipient
+type NotificationRecipientConfig struct {
+	// Events [optional]
+	//
+	// If defined, only notifications with one of these events will be delivered to the recipient
+	Events []NotificationEvent
+
+	// Sources [optional]
+	//
+	// If defined, only notifications from one of these sources will be delivered to the recipient
+	Sources []NotificationSource
+
+	// Filter [optional]
+	//
+	// If defined, only notifications this func returns true for will be delivered to the recipient.  It is evaluated
+	// after Events and Sources.
+	Filter NotificationFilter
+
+	// BufferSize [optional]
+	//
+	// Number of notifications that may be queued for the recipient before OverflowPolicy is applied.  Defaults to
+	// NotificationDefaultBufferSize.
+	BufferSize int
+
+	// BlockTimeout [optional]
+	//
+	// Maximum amount of time a queued notification will wait for the handler to accept it before being dropped.
+	// When OverflowPolicy is NotificationOverflowBlock, this is instead the maximum amount of time the producer will
+	// wait for room in the buffer.  Defaults to NotificationDefaultBlockTimeout.
+	BlockTimeout time.Duration
+
+	// OverflowPolicy [optional]
+	//
+	// What to do when the buffer is full.  Defaults to NotificationOverflowDropNewest.
+	OverflowPolicy NotificationOverflowPolicy
+
+	// Replay [optional]
+	//
+	// If true, the most recent notification previously sent for each source and event is queued for the recipient,
+	// oldest first, as it is attached.  Replayed notifications are subject to Events, Sources, and Filter.
+	Replay bool
+
+	// SyncEvents [optional]
+	//
+	// Notifications with one of these events are delivered synchronously: the producer blocks until the handler has
+	// returned, or until SyncTimeout has passed.  They remain ordered with respect to all other notifications for the
+	// recipient, and are only dropped if SyncTimeout passes while waiting for room in the buffer.
+	//
+	// As the producer is blocked, handlers of synchronous events must not call back into the producer.
+	SyncEvents []NotificationEvent
+
+	// SyncTimeout [optional]
+	//
+	// Maximum amount of time the producer will wait on this recipient per synchronous notification.  Defaults to
+	// NotificationDefaultSyncTimeout.
+	SyncTimeout time.Duration
+}
+
+// NotificationRecipientMutator defines a callback that may mutate the config of a recipient being attached to a
+// Notifier
+type NotificationRecipientMutator func(*NotificationRecipientConfig)
+
+// WithNotificationEvents limits a recipient to the provided events
+func WithNotificationEvents(evs ...NotificationEvent) NotificationRecipientMutator {
+	return func(cfg *NotificationRecipientConfig) {
+		cfg.Events = append(cfg.Events, evs...)
+	}
+}
+
+// WithNotificationSources limits a recipient to notifications from the provided sources
+func WithNotificationSources(srcs ...NotificationSource) NotificationRecipientMutator {
+	return func(cfg *NotificationRecipientConfig) {
+		cfg.Sources = append(cfg.Sources, srcs...)
+	}
+}
+
+// WithNotificationFilter limits a recipient to notifications the provided func returns true for.  If called more than
+// once, all filters must return true for the notification to be delivered.
+func WithNotificationFilter(fn NotificationFilter) NotificationRecipientMutator {
+	return func(cfg *NotificationRecipientConfig) {
+		if fn == nil {
+			return
+		}
+		if prev := cfg.Filter; prev != nil {
+			cfg.Filter = func(n Notification) bool { return prev(n) && fn(n) }
+		} else {
+			cfg.Filter = fn
+		}
+	}
+}
+
+// WithNotificationBuffer sets the buffer size and overflow policy of a recipient
+func WithNotificationBuffer(size int, policy NotificationOverflowPolicy) NotificationRecipientMutator {
+	return func(cfg *NotificationRecipientConfig) {
+		cfg.BufferSize = size
+		cfg.OverflowPolicy = policy
+	}
+}
+
+// WithNotificationBlockTimeout sets the block timeout of a recipient
+func WithNotificationBlockTimeout(d time.Duration) NotificationRecipientMutator {
+	return func(cfg *NotificationRecipientConfig) {
+		cfg.BlockTimeout = d
+	}
+}
+
+// WithNotificationSync causes notifications with the provided events to be delivered synchronously, with the producer
+// waiting for up to timeout for the handler to return.  If timeout is zero, NotificationDefaultSyncTimeout is used.
+func WithNotificationSync(timeout time.Duration, evs ...NotificationEvent) NotificationRecipientMutator {
+	return func(cfg *NotificationRecipientConfig) {
+		cfg.SyncEvents = append(cfg.SyncEvents, evs...)
+		cfg.SyncTimeout = timeout
+	}
+}
+
+// WithNotificationReplay causes the latest previously sent notification for each source and event to be delivered to
+// the recipient as it is attached
+func WithNotificationReplay() NotificationRecipientMutator {
+	return func(cfg *NotificationRecipientConfig) {
+		cfg.Replay = true
+	}
+}
+
+// buildNotificationRecipientConfig applies each mutator to a new config, setting defaults for any unset fields
+func buildNotificationRecipientConfig(fns ...NotificationRecipientMutator) *NotificationRecipientConfig {
+	cfg := new(NotificationRecipientConfig)
+	for _, fn := range fns {
+		if fn != nil {
+			fn(cfg)
+		}
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = NotificationDefaultBufferSize
+	}
+	if cfg.BlockTimeout <= 0 {
+		cfg.BlockTimeout = NotificationDefaultBlockTimeout
+	}
+	if cfg.SyncTimeout <= 0 {
+		cfg.SyncTimeout = NotificationDefaultSyncTimeout
+	}
+	return cfg
+}
+
 // Notifier represents a type within Consultant that can push in-process notifications to things.
 type Notifier interface {
 	// AttachNotificationHandler must immediately add the provided fn to the list of recipients for new notifications.
@@ -156,7 +440,8 @@ type Notifier interface {
 	// - panic if fn is nil
 	// - generate random ID if provided ID is empty
 	// - return "true" if there was an existing recipient with the same identifier
-	AttachNotificationHandler(id string, fn NotificationHandler) (actualID string, replaced bool)
+	// - only deliver notifications matching the Events, Sources, and Filter of the provided mutators, if any
+	AttachNotificationHandler(id string, fn NotificationHandler, fns ...NotificationRecipientMutator) (actualID string, replaced bool)
 
 	// AttachNotificationChannel must immediately add the provided channel to the list of recipients for new
 	// notifications.
@@ -165,7 +450,8 @@ type Notifier interface {
 	// - panic if ch is nil
 	// - generate random ID if provided ID is empty
 	// - return "true" if there was an existing recipient with the same identifier
-	AttachNotificationChannel(id string, ch NotificationChannel) (actualID string, replaced bool)
+	// - only deliver notifications matching the Events, Sources, and Filter of the provided mutators, if any
+	AttachNotificationChannel(id string, ch NotificationChannel, fns ...NotificationRecipientMutator) (actualID string, replaced bool)
 
 	// DetachNotificationRecipient must immediately remove the provided ID from the list of recipients for new
 	// notifications, if exists.  It will return true if a recipient was found with that id.
@@ -176,6 +462,31 @@ type Notifier interface {
 	//
 	// if wait is true, this method will block until all handlers have been closed
 	DetachAllNotificationRecipients(wait bool) int
+
+	// AttachNotificationHandlerContext must immediately add the provided fn to the list of recipients for new
+	// notifications under a random ID, returning that ID.
+	//
+	// It must:
+	// - panic if fn is nil
+	// - detach the recipient and discard any notifications queued for it once ctx is done
+	AttachNotificationHandlerContext(ctx context.Context, fn NotificationHandler, fns ...NotificationRecipientMutator) (actualID string)
+
+	// AttachNotificationChannelContext must immediately add the provided channel to the list of recipients for new
+	// notifications under a random ID, returning that ID.
+	//
+	// It must:
+	// - panic if ch is nil
+	// - detach the recipient, abandon any pending push, and discard any notifications queued for it once ctx is done
+	AttachNotificationChannelContext(ctx context.Context, ch NotificationChannel, fns ...NotificationRecipientMutator) (actualID string)
+
+	// DetachAllNotificationRecipientsContext must immediately expunge all registered recipients, returning the count of
+	// those detached.  It must then block until all handlers have been closed or ctx is done, returning ctx.Err() in the
+	// latter case.
+	DetachAllNotificationRecipientsContext(ctx context.Context) (int, error)
+
+	// NotificationRecipientDropped must return the number of notifications dropped for the provided recipient due to
+	// backpressure, and false if no recipient exists with that id.
+	NotificationRecipientDropped(id string) (dropped uint64, ok bool)
 }
 
 type NotifierAttachResult struct {
@@ -183,26 +494,114 @@ type NotifierAttachResult struct {
 	Overwrote bool
 }
 
+// notifierDelivery wraps a notification queued for a worker.  ack will be non-nil for synchronous deliveries, and is
+// closed once the notification has either been handled or dropped.
+type notifierDelivery struct {
+	n   Notification
+	ack chan struct{}
+}
+
+func (d notifierDelivery) release() {
+	if d.ack != nil {
+		close(d.ack)
+	}
+}
+
 type notifierWorker struct {
-	mu     sync.RWMutex
-	closed bool
-	wg     *sync.WaitGroup
-	in     chan Notification
-	out    chan Notification
-	fn     NotificationHandler
+	mu          sync.RWMutex
+	pmu         sync.Mutex
+	closed      bool
+	wg          *sync.WaitGroup
+	in          chan notifierDelivery
+	out         chan notifierDelivery
+	fn          NotificationHandler
+	events      map[NotificationEvent]struct{}
+	sources     map[NotificationSource]struct{}
+	filter      NotificationFilter
+	syncEvents  map[NotificationEvent]struct{}
+	syncTimeout time.Duration
+	timeout     time.Duration
+	policy      NotificationOverflowPolicy
+	seq         uint64
+	dropped     uint64
+	done        chan struct{}
+	metrics     MetricsRecorder
 }
 
-func newNotifierWorker(id string, wg *sync.WaitGroup, fn NotificationHandler) *notifierWorker {
+func newNotifierWorker(id string, wg *sync.WaitGroup, fn NotificationHandler, cfg *NotificationRecipientConfig, metrics MetricsRecorder) *notifierWorker {
 	nw := new(notifierWorker)
-	nw.in = make(chan Notification, 100)
-	nw.out = make(chan Notification)
+	nw.metrics = metrics
+	nw.in = make(chan notifierDelivery, cfg.BufferSize)
+	nw.out = make(chan notifierDelivery)
+	nw.done = make(chan struct{})
 	nw.wg = wg
 	nw.fn = fn
+	if len(cfg.Events) > 0 {
+		nw.events = make(map[NotificationEvent]struct{}, len(cfg.Events))
+		for _, ev := range cfg.Events {
+			nw.events[ev] = struct{}{}
+		}
+	}
+	if len(cfg.Sources) > 0 {
+		nw.sources = make(map[NotificationSource]struct{}, len(cfg.Sources))
+		for _, src := range cfg.Sources {
+			nw.sources[src] = struct{}{}
+		}
+	}
+	if len(cfg.SyncEvents) > 0 {
+		nw.syncEvents = make(map[NotificationEvent]struct{}, len(cfg.SyncEvents))
+		for _, ev := range cfg.SyncEvents {
+			nw.syncEvents[ev] = struct{}{}
+		}
+	}
+	nw.filter = cfg.Filter
+	nw.syncTimeout = cfg.SyncTimeout
+	nw.timeout = cfg.BlockTimeout
+	nw.policy = cfg.OverflowPolicy
 	go nw.publish()
 	go nw.process()
 	return nw
 }
 
+// accepts returns true if the provided notification passes this worker's filters
+func (nw *notifierWorker) accepts(n Notification) bool {
+	if nw.events != nil {
+		if _, ok := nw.events[n.Event]; !ok {
+			return false
+		}
+	}
+	if nw.sources != nil {
+		if _, ok := nw.sources[n.Source]; !ok {
+			return false
+		}
+	}
+	if nw.filter != nil {
+		return nw.filter(n)
+	}
+	return true
+}
+
+// synchronous returns true if the provided event must be delivered synchronously to this worker
+func (nw *notifierWorker) synchronous(ev NotificationEvent) bool {
+	if nw.syncEvents == nil {
+		return false
+	}
+	_, ok := nw.syncEvents[ev]
+	return ok
+}
+
+func (nw *notifierWorker) drop(d notifierDelivery) {
+	atomic.AddUint64(&nw.dropped, 1)
+	if nw.metrics != nil {
+		nw.metrics.NotificationDropped(d.n)
+	}
+	d.release()
+}
+
+func (nw *notifierWorker) droppedCount() uint64 {
+	return atomic.LoadUint64(&nw.dropped)
+}
+
 func (nw *notifierWorker) close() {
 	nw.mu.Lock()
 	defer nw.mu.Unlock()
@@ -211,81 +610,239 @@ func (nw *notifierWorker) close() {
 		return
 	}
 
+	// publish() releases anything still queued, and closes nw.out once nw.in has been drained
 	nw.closed = true
+	close(nw.done)
 	close(nw.in)
-	close(nw.out)
-	if len(nw.in) > 0 {
-		for range nw.in {
-		}
-	}
 }
 
 func (nw *notifierWorker) publish() {
 	var wait *time.Timer
 
+	// every receive from wait.C is accounted for below, so the timer need only be stopped here
 	defer func() {
-		if wait != nil && !wait.Stop() {
-			<-wait.C
+		if wait != nil {
+			wait.Stop()
 		}
+		close(nw.out)
 	}()
 
-	for n := range nw.in {
-		// todo: it is probably not necessary to test here as if the worker is closed between this notification
-		// being processed and the next notification in, it is removed from the map of available workers to push
-		// to before close == true, meaning it cannot have new messages pushed to it.
-		nw.mu.RLock()
-		if nw.closed {
-			nw.mu.RUnlock()
-			return
+	for d := range nw.in {
+		// once closed, anything remaining is released without being handled
+		select {
+		case <-nw.done:
+			d.release()
+			continue
+		default:
+		}
+
+		// when blocking, backpressure is applied to the producer rather than here, so wait for the handler for as
+		// long as it takes or until the worker is closed.  synchronous deliveries are bounded by the producer's own
+		// timeout.
+		if nw.policy == NotificationOverflowBlock || d.ack != nil {
+			select {
+			case nw.out <- d:
+			case <-nw.done:
+				d.release()
+			}
+			continue
 		}
 
 		// either create or reset timer
 		if wait == nil {
-			wait = time.NewTimer(5 * time.Second)
+			wait = time.NewTimer(nw.timeout)
 		} else {
-			wait.Reset(5 * time.Second)
+			wait.Reset(nw.timeout)
 		}
 
-		// attempt to push message to consumer, allowing for up to 5 seconds of blocking
+		// attempt to push message to consumer, allowing for up to timeout of blocking
 		// if block window passes, drop on floor
 		select {
-		case nw.out <- n:
+		case nw.out <- d:
 			if !wait.Stop() {
 				<-wait.C
 			}
 		case <-wait.C:
+			nw.drop(d)
+		case <-nw.done:
+			if !wait.Stop() {
+				<-wait.C
+			}
+			d.release()
 		}
-
-		nw.mu.RUnlock()
 	}
 }
 
 func (nw *notifierWorker) process() {
 	// nw.out is an unbuffered channel.  it blocks until any preceding notification has been handled by the registered
 	// handler.  it is closed once the publish() loop breaks.
-	for n := range nw.out {
-		nw.fn(n)
+	for d := range nw.out {
+		nw.fn(d.n)
+		d.release()
 	}
 
 	// mark done only after nw.out loop has exited
 	nw.wg.Done()
 }
 
-func (nw *notifierWorker) push(n Notification) {
+// push queues n for this worker if it passes the worker's filters.  if n must be delivered synchronously, either as
+// the worker requires it or as wait is greater than zero, the returned channel will be closed once it has been handled
+// or dropped.
+func (nw *notifierWorker) push(n Notification, wait time.Duration) <-chan struct{} {
+	// filter before queueing so unwanted notifications never occupy space in the ingest chan
+	if !nw.accepts(n) {
+		return nil
+	}
+
 	// hold an rlock for the duration of the push attempt to ensure that, at a minimum, the message is added to the
 	// channel before it can be closed.
 	nw.mu.RLock()
 	defer nw.mu.RUnlock()
 
+	// hold the push lock while sequencing and queueing so sequence numbers are always queued in order
+	nw.pmu.Lock()
+	defer nw.pmu.Unlock()
+
+	return nw.pushLocked(n, wait)
+}
+
+// pushLocked queues n for this worker, applying its overflow policy if the buffer is full.  if wait is greater than
+// zero, n is delivered synchronously regardless of the worker's configuration, waiting up to wait for room.
+//
+// caller must hold rlock and push lock, and have already filtered n
+func (nw *notifierWorker) pushLocked(n Notification, wait time.Duration) <-chan struct{} {
 	if nw.closed {
-		return
+		return nil
 	}
 
-	// attempt to push message to ingest chan.  if chan is full, drop on floor
+	nw.seq++
+	n.Sequence = nw.seq
+
+	d := notifierDelivery{n: n}
+	timeout := nw.syncTimeout
+	if wait > 0 {
+		d.ack = make(chan struct{})
+		timeout = wait
+	} else if nw.synchronous(n.Event) {
+		d.ack = make(chan struct{})
+	}
+
+	// attempt to push message to ingest chan.
 	select {
-	case nw.in <- n:
+	case nw.in <- d:
+		return d.ack
+	default:
+	}
+
+	// synchronous deliveries always wait for room
+	if d.ack != nil {
+		nw.pushBlock(d, timeout)
+		return d.ack
+	}
+
+	// chan is full, apply overflow policy
+	switch nw.policy {
+	case NotificationOverflowDropOldest:
+		nw.pushDropOldest(d)
+	case NotificationOverflowBlock:
+		nw.pushBlock(d, nw.timeout)
+	case NotificationOverflowCoalesce:
+		nw.pushCoalesce(d)
+
 	default:
+		nw.drop(d)
 	}
+
+	return nil
+}
+
+// pushDropOldest evicts buffered notifications until d fits
+//
+// caller must hold rlock and push lock
+func (nw *notifierWorker) pushDropOldest(d notifierDelivery) {
+	for {
+		select {
+		case nw.in <- d:
+			return
+		default:
+		}
+		select {
+		case q := <-nw.in:
+			nw.drop(q)
+		default:
+		}
+	}
+}
+
+// pushBlock waits up to the provided timeout for room in the buffer
+//
+// caller must hold rlock and push lock
+func (nw *notifierWorker) pushBlock(d notifierDelivery, timeout time.Duration) {
+	wait := time.NewTimer(timeout)
+	defer wait.Stop()
+	select {
+	case nw.in <- d:
+	case <-wait.C:
+		nw.drop(d)
+	}
+}
+
+// pushCoalesce drains the buffer, discarding any notification superseded by a later one with the same source and
+// event, then requeues what remains along with d.  if there is still no room, the oldest notifications are dropped.
+//
+// caller must hold rlock and push lock
+func (nw *notifierWorker) pushCoalesce(d notifierDelivery) {
+	type key struct {
+		src NotificationSource
+		ev  NotificationEvent
+	}
+
+	var (
+		queued = make([]notifierDelivery, 0, cap(nw.in)+1)
+		latest = make(map[key]int, cap(nw.in)+1)
+	)
+
+drain:
+	for {
+		select {
+		case q := <-nw.in:
+			queued = append(queued, q)
+		default:
+			break drain
+		}
+	}
+	queued = append(queued, d)
+
+	for i, q := range queued {
+		latest[key{q.n.Source, q.n.Event}] = i
+	}
+
+	keep := queued[:0]
+	for i, q := range queued {
+		if latest[key{q.n.Source, q.n.Event}] == i {
+			keep = append(keep, q)
+		} else {
+			nw.drop(q)
+		}
+	}
+
+	// requeue what remains, evicting from the front if there is no room
+	for len(keep) > 0 {
+		select {
+		case nw.in <- keep[0]:
+		default:
+			nw.drop(keep[0])
+		}
+		keep = keep[1:]
+	}
+}
+
+// notifierLastKey identifies the most recent notification of each kind.  producer is only set on a NotificationBus,
+// where it ensures the latest state of every producer is retained rather than only that of whichever sent last.
+type notifierLastKey struct {
+	producer string
+	source   NotificationSource
+	event    NotificationEvent
 }
 
 type notifierBase struct {
@@ -293,12 +850,25 @@ type notifierBase struct {
 	workers map[string]*notifierWorker
 	hr      chan *notifierWorker
 	wg      *sync.WaitGroup
+
+	lmu  sync.Mutex
+	last map[notifierLastKey]Notification
+
+	instance string
+	seq      uint64
+
+	metrics MetricsRecorder
+
+	log StructuredLogger
 }
 
-func newNotifierBase(log Logger, debug bool) *notifierBase {
+func newNotifierBase(log StructuredLogger) *notifierBase {
 	nb := new(notifierBase)
+	nb.instance = LazyRandomString(12)
+	nb.log = log
 	nb.workers = make(map[string]*notifierWorker)
 	nb.wg = new(sync.WaitGroup)
+	nb.last = make(map[notifierLastKey]Notification)
 	return nb
 }
 
@@ -310,7 +880,7 @@ type BasicNotifier struct {
 // NewBasicNotifier returns the default Notifier implementation
 func NewBasicNotifier(log Logger, debug bool) *BasicNotifier {
 	b := new(BasicNotifier)
-	b.notifierBase = newNotifierBase(log, debug)
+	b.notifierBase = newNotifierBase(buildStructuredLogger(nil, log, debug))
 	return b
 }
 
@@ -319,31 +889,89 @@ func (bn *BasicNotifier) Push(s NotificationSource, ev NotificationEvent, d inte
 	bn.sendNotification(s, ev, d)
 }
 
-// AttachNotificationHandler immediately adds the provided handler to the list of handlers to be called per notification
-func (nb *notifierBase) AttachNotificationHandler(id string, fn NotificationHandler) (string, bool) {
+// AttachNotificationHandler immediately adds the provided handler to the list of handlers to be called per notification.
+// Any provided mutators may be used to limit which notifications the handler receives.
+func (nb *notifierBase) AttachNotificationHandler(id string, fn NotificationHandler, fns ...NotificationRecipientMutator) (string, bool) {
 	if fn == nil {
 		panic(fmt.Sprintf("AttachNotificationHandler called with id %q and nil handler", id))
 	}
-	var (
-		w        *notifierWorker
-		replaced bool
-	)
+	id, _, replaced := nb.attach(id, fn, fns...)
+	return id, replaced
+}
+
+// AttachNotificationHandlerContext immediately adds the provided handler to the list of handlers to be called per
+// notification, returning its randomly generated ID.  Once ctx is done the handler is detached and any notifications
+// still queued for it are discarded.
+func (nb *notifierBase) AttachNotificationHandlerContext(ctx context.Context, fn NotificationHandler, fns ...NotificationRecipientMutator) string {
+	if fn == nil {
+		panic("AttachNotificationHandlerContext called with nil handler")
+	}
+	id, w, _ := nb.attach("", fn, fns...)
+	go nb.detachOnDone(ctx, id, w)
+	return id
+}
 
+// attach constructs and registers a new worker, closing any existing worker registered with the same id
+func (nb *notifierBase) attach(id string, fn NotificationHandler, fns ...NotificationRecipientMutator) (string, *notifierWorker, bool) {
 	nb.mu.Lock()
-	defer nb.mu.Unlock()
 
 	nb.wg.Add(1)
 
 	if id == "" {
 		id = LazyRandomString(12)
 	}
-	w, replaced = nb.workers[id]
+	prev, replaced := nb.workers[id]
+
+	cfg := buildNotificationRecipientConfig(fns...)
+	w := newNotifierWorker(id, nb.wg, fn, cfg, nb.metrics)
+
+	// the replay snapshot is taken and the worker's push lock acquired before it becomes visible to dispatch, so no
+	// new notification may be queued for it ahead of the replay.  the replay itself is queued after releasing the
+	// write lock so that a slow recipient cannot hold up other producers and recipients.
+	var replay []Notification
+	if cfg.Replay {
+		w.mu.RLock()
+		w.pmu.Lock()
+		replay = nb.LastNotifications()
+	}
+
+	nb.workers[id] = w
+	nb.mu.Unlock()
 
-	nb.workers[id] = newNotifierWorker(id, nb.wg, fn)
 	if replaced {
-		w.close()
+		prev.close()
 	}
-	return id, replaced
+
+	if cfg.Replay {
+		for _, n := range replay {
+			if w.accepts(n) {
+				w.pushLocked(n, 0)
+			}
+		}
+		w.pmu.Unlock()
+		w.mu.RUnlock()
+	}
+
+	return id, w, replaced
+}
+
+// detachOnDone blocks until either ctx is done, at which point the worker is detached, or the worker has been closed
+// by some other means
+func (nb *notifierBase) detachOnDone(ctx context.Context, id string, w *notifierWorker) {
+	select {
+	case <-ctx.Done():
+	case <-w.done:
+		return
+	}
+
+	nb.mu.Lock()
+	// only remove the worker if it has not since been replaced
+	if curr, ok := nb.workers[id]; ok && curr == w {
+		delete(nb.workers, id)
+	}
+	nb.mu.Unlock()
+
+	w.close()
 }
 
 // AttachNotificationHandlers allows you to attach 1 or more notification handlers at a time
@@ -363,14 +991,30 @@ func (nb *notifierBase) AttachNotificationHandlers(fns ...NotificationHandler) [
 	return results
 }
 
-// AttachNotificationChannel will register a new channel for notifications to be pushed to
-func (nb *notifierBase) AttachNotificationChannel(id string, ch NotificationChannel) (string, bool) {
+// AttachNotificationChannel will register a new channel for notifications to be pushed to.  Any provided mutators may
+// be used to limit which notifications are pushed to the channel.
+func (nb *notifierBase) AttachNotificationChannel(id string, ch NotificationChannel, fns ...NotificationRecipientMutator) (string, bool) {
 	if ch == nil {
 		panic(fmt.Sprintf("AttachNotificationChannel called with id %q and nil channel", id))
 	}
 	return nb.AttachNotificationHandler(id, func(n Notification) {
 		ch <- n
-	})
+	}, fns...)
+}
+
+// AttachNotificationChannelContext will register a new channel for notifications to be pushed to, returning its
+// randomly generated ID.  Once ctx is done the channel is detached, any pending push to it is abandoned, and any
+// notifications still queued for it are discarded.  The channel is not closed.
+func (nb *notifierBase) AttachNotificationChannelContext(ctx context.Context, ch NotificationChannel, fns ...NotificationRecipientMutator) string {
+	if ch == nil {
+		panic("AttachNotificationChannelContext called with nil channel")
+	}
+	return nb.AttachNotificationHandlerContext(ctx, func(n Notification) {
+		select {
+		case ch <- n:
+		case <-ctx.Done():
+		}
+	}, fns...)
 }
 
 // AttachNotificationChannels will attempt to attach multiple channels at once
@@ -408,6 +1052,39 @@ func (nb *notifierBase) DetachNotificationRecipient(id string) bool {
 // DetachAllNotificationRecipients immediately clears all attached recipients, returning the count of those previously
 // attached.
 func (nb *notifierBase) DetachAllNotificationRecipients(wait bool) int {
+	cnt, wg := nb.detachAll()
+
+	// if we were told to wait, wait for old wg
+	if wait {
+		wg.Wait()
+	}
+
+	return cnt
+}
+
+// DetachAllNotificationRecipientsContext immediately clears all attached recipients, returning the count of those
+// previously attached.  It then blocks until either all handlers have been closed or ctx is done, in which case
+// ctx.Err() is returned.
+func (nb *notifierBase) DetachAllNotificationRecipientsContext(ctx context.Context) (int, error) {
+	cnt, wg := nb.detachAll()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return cnt, nil
+	case <-ctx.Done():
+		return cnt, ctx.Err()
+	}
+}
+
+// detachAll closes and removes all workers, returning the count of those removed and the wait group they were tracked
+// by
+func (nb *notifierBase) detachAll() (int, *sync.WaitGroup) {
 	nb.mu.Lock()
 
 	// get current count of workers
@@ -427,26 +1104,301 @@ func (nb *notifierBase) DetachAllNotificationRecipients(wait bool) int {
 
 	nb.mu.Unlock()
 
-	// if we were told to wait, wait for old wg
-	if wait {
-		wg.Wait()
+	return cnt, wg
+}
+
+// NotificationRecipientDropped returns the number of notifications dropped for the recipient with the provided id due
+// to backpressure.  The count is reset if the recipient is replaced.
+func (nb *notifierBase) NotificationRecipientDropped(id string) (uint64, bool) {
+	nb.mu.RLock()
+	defer nb.mu.RUnlock()
+	if w, ok := nb.workers[id]; ok {
+		return w.droppedCount(), true
 	}
+	return 0, false
+}
 
-	return cnt
+// LastNotification returns the most recently sent notification with the provided source and event, if one has been
+// sent.  On a NotificationBus, this is the most recent across all producers.
+func (nb *notifierBase) LastNotification(s NotificationSource, ev NotificationEvent) (Notification, bool) {
+	var (
+		out Notification
+		ok  bool
+	)
+	nb.lmu.Lock()
+	defer nb.lmu.Unlock()
+	for k, n := range nb.last {
+		if k.source == s && k.event == ev && (!ok || n.Originated > out.Originated) {
+			out, ok = n, true
+		}
+	}
+	return out, ok
 }
 
-// sendNotification immediately calls each handler with the new notification
-func (nb *notifierBase) sendNotification(s NotificationSource, ev NotificationEvent, d interface{}) {
+// LastNotifications returns the most recently sent notification for each source and event, oldest first.  On a
+// NotificationBus, this includes the most recent of each producer.
+func (nb *notifierBase) LastNotifications() []Notification {
+	nb.lmu.Lock()
+	out := make([]Notification, 0, len(nb.last))
+	for _, n := range nb.last {
+		out = append(out, n)
+	}
+	nb.lmu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Originated < out[j].Originated })
+	return out
+}
+
+// InstanceID returns the random identifier of this producer, used as the prefix of the ID of each notification it
+// sends
+func (nb *notifierBase) InstanceID() string {
+	return nb.instance
+}
+
+// sendNotification immediately calls each handler with the new notification, returning its ID
+func (nb *notifierBase) sendNotification(s NotificationSource, ev NotificationEvent, d interface{}) string {
+	return nb.sendCausedNotification("", s, ev, d)
+}
+
+// sendCausedNotification immediately calls each handler with a new notification caused by the notification with the
+// provided id, returning the new notification's ID
+func (nb *notifierBase) sendCausedNotification(cause string, s NotificationSource, ev NotificationEvent, d interface{}) string {
+	n := Notification{
+		ID:         nb.nextNotificationID(),
+		CauseID:    cause,
+		Originated: time.Now().UnixNano(),
+		Source:     s,
+		Event:      ev,
+		Data:       d,
+	}
+	nb.dispatch(n, 0)
+	return n.ID
+}
+
+// sendNotificationWait sends a new notification that is delivered synchronously to every recipient, regardless of
+// their configuration, blocking until each has handled it or wait has passed.  It is intended for notifications that
+// must be delivered before the process exits.
+func (nb *notifierBase) sendNotificationWait(wait time.Duration, s NotificationSource, ev NotificationEvent, d interface{}) string {
 	n := Notification{
-		ID:         LazyRandomString(64),
+		ID:         nb.nextNotificationID(),
 		Originated: time.Now().UnixNano(),
 		Source:     s,
 		Event:      ev,
 		Data:       d,
 	}
+	nb.dispatch(n, wait)
+	return n.ID
+}
+
+func (nb *notifierBase) nextNotificationID() string {
+	return nb.instance + "-" + strconv.FormatUint(atomic.AddUint64(&nb.seq, 1), 10)
+}
+
+// dispatch records n as the latest of its source and event, then pushes it to each worker.  if any worker requires
+// synchronous delivery of n, this blocks until each such worker has handled it or its sync timeout has passed.  if wait
+// is greater than zero, every worker is treated as requiring synchronous delivery with a timeout of wait.
+func (nb *notifierBase) dispatch(n Notification, wait time.Duration) {
+	type pending struct {
+		id      string
+		ack     <-chan struct{}
+		timeout time.Duration
+	}
+
+	type target struct {
+		id string
+		w  *notifierWorker
+	}
+
+	var (
+		targets []target
+		acks    []pending
+	)
+
+	// n is recorded and the workers snapshot under the same rlock, so any worker attached afterwards will see n in its
+	// replay rather than also being pushed it here.  the lock is released before pushing, as a blocking push may wait
+	// for as long as the worker's timeout and must not hold up other producers or attach and detach calls.
 	nb.mu.RLock()
-	for _, w := range nb.workers {
-		w.push(n)
+	nb.lmu.Lock()
+	nb.last[notifierLastKey{producer: n.Producer, source: n.Source, event: n.Event}] = n
+	nb.lmu.Unlock()
+	targets = make([]target, 0, len(nb.workers))
+	for id, w := range nb.workers {
+		targets = append(targets, target{id: id, w: w})
 	}
 	nb.mu.RUnlock()
+
+	for _, t := range targets {
+		if ack := t.w.push(n, wait); ack != nil {
+			timeout := t.w.syncTimeout
+			if wait > 0 {
+				timeout = wait
+			}
+			acks = append(acks, pending{id: t.id, ack: ack, timeout: timeout})
+		}
+	}
+
+	if len(acks) == 0 {
+		return
+	}
+
+	start := time.Now()
+	for _, p := range acks {
+		wait := time.NewTimer(p.timeout - time.Since(start))
+		select {
+		case <-p.ack:
+		case <-wait.C:
+			nb.log.Warn(
+				"Recipient did not handle synchronous notification in time",
+				"recipient", p.id,
+				"notification_id", n.ID,
+				LogKeyEvent, n.Event.String(),
+				"timeout", p.timeout,
+			)
+		}
+		wait.Stop()
+	}
+}
+
+// TypedNotification is a Notification whose Data has been asserted to type T.  The original, untyped Notification is
+// embedded.
+type TypedNotification[T any] struct {
+	Notification
+	Data T
+}
+
+// NotificationTypeMismatchError is recorded by a Subscription when a notification's Data is not of the expected type
+type NotificationTypeMismatchError struct {
+	Notification Notification
+	Expected     string
+}
+
+func (e *NotificationTypeMismatchError) Error() string {
+	return fmt.Sprintf(
+		"notification %s from %s with event %s: expected data to be of type %s, saw %T",
+		e.Notification.ID,
+		e.Notification.Source,
+		e.Notification.Event,
+		e.Expected,
+		e.Notification.Data,
+	)
+}
+
+// Subscription represents a typed recipient attached to a Notifier by either Subscribe or SubscribeFunc
+type Subscription[T any] struct {
+	mu         sync.Mutex
+	id         string
+	closed     bool
+	ch         chan TypedNotification[T]
+	ctx        context.Context
+	cancel     context.CancelFunc
+	done       chan struct{}
+	mismatches uint64
+	errMu      sync.Mutex
+	lastErr    error
+
+	// C will be non-nil when created by Subscribe, and is closed once the subscription has ended
+	C <-chan TypedNotification[T]
+}
+
+// Subscribe attaches a recipient to n that pushes each notification whose Data is of type T onto the returned
+// Subscription's C.  If events are provided, only notifications with those events are delivered.  The recipient is
+// detached and C is closed once ctx is done or Unsubscribe is called.
+//
+// C must be read from until closed, as it is unbuffered.
+func Subscribe[T any](ctx context.Context, n Notifier, events ...NotificationEvent) *Subscription[T] {
+	s := newSubscription[T](ctx)
+	s.ch = make(chan TypedNotification[T])
+	s.C = s.ch
+	s.attach(n, func(tn TypedNotification[T], ctx context.Context) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.closed {
+			return
+		}
+		select {
+		case s.ch <- tn:
+		case <-ctx.Done():
+		}
+	}, events)
+	return s
+}
+
+// SubscribeFunc attaches a recipient to n that calls fn with each notification whose Data is of type T.  If events are
+// provided, only notifications with those events are delivered.  The recipient is detached once ctx is done or
+// Unsubscribe is called.
+func SubscribeFunc[T any](ctx context.Context, n Notifier, fn func(TypedNotification[T]), events ...NotificationEvent) *Subscription[T] {
+	if fn == nil {
+		panic("SubscribeFunc called with nil fn")
+	}
+	s := newSubscription[T](ctx)
+	s.attach(n, func(tn TypedNotification[T], _ context.Context) {
+		fn(tn)
+	}, events)
+	return s
+}
+
+func newSubscription[T any](ctx context.Context) *Subscription[T] {
+	s := new(Subscription[T])
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	return s
+}
+
+func (s *Subscription[T]) attach(n Notifier, fn func(TypedNotification[T], context.Context), events []NotificationEvent) {
+	ctx := s.ctx
+
+	s.id, _ = n.AttachNotificationHandler("", func(n Notification) {
+		data, ok := n.Data.(T)
+		if !ok {
+			s.mismatch(n)
+			return
+		}
+		fn(TypedNotification[T]{Notification: n, Data: data}, ctx)
+	}, WithNotificationEvents(events...))
+
+	go func() {
+		<-ctx.Done()
+		n.DetachNotificationRecipient(s.id)
+		s.mu.Lock()
+		s.closed = true
+		if s.ch != nil {
+			close(s.ch)
+		}
+		s.mu.Unlock()
+		close(s.done)
+	}()
+}
+
+func (s *Subscription[T]) mismatch(n Notification) {
+	err := &NotificationTypeMismatchError{Notification: n, Expected: reflect.TypeOf((*T)(nil)).Elem().String()}
+	atomic.AddUint64(&s.mismatches, 1)
+	s.errMu.Lock()
+	s.lastErr = err
+	s.errMu.Unlock()
+}
+
+// ID returns the recipient ID of this subscription within the Notifier it was attached to
+func (s *Subscription[T]) ID() string {
+	return s.id
+}
+
+// Mismatches returns the number of notifications that were discarded because their Data was not of type T
+func (s *Subscription[T]) Mismatches() uint64 {
+	return atomic.LoadUint64(&s.mismatches)
+}
+
+// Err returns a *NotificationTypeMismatchError describing the most recently discarded notification, if any
+func (s *Subscription[T]) Err() error {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	return s.lastErr
+}
+
+// Done returns a channel that is closed once the subscription has been detached
+func (s *Subscription[T]) Done() <-chan struct{} {
+	return s.done
+}
+
+// Unsubscribe detaches this subscription from its Notifier.  It is safe to call multiple times.
+func (s *Subscription[T]) Unsubscribe() {
+	s.cancel()
 }
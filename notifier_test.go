@@ -1,6 +1,9 @@
 package consultant_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"sync"
@@ -220,3 +223,593 @@ func TestBasicNotifier(t *testing.T) {
 		bn.DetachAllNotificationRecipients(true)
 	})
 }
+
+func TestNotifierBase_Filters(t *testing.T) {
+	var (
+		evCnt, srcCnt, fnCnt, allCnt uint64
+
+		wg = new(sync.WaitGroup)
+		bn = consultant.NewBasicNotifier(log.New(os.Stdout, "==> Notifier ", log.LstdFlags), true)
+	)
+
+	defer bn.DetachAllNotificationRecipients(false)
+
+	wg.Add(3 + 3 + 1 + 4)
+
+	bn.AttachNotificationHandler("events", func(n consultant.Notification) {
+		if n.Event != consultant.NotificationEventTestPush {
+			t.Logf("events recipient saw unexpected event %s", n.Event)
+			t.Fail()
+		}
+		atomic.AddUint64(&evCnt, 1)
+		wg.Done()
+	}, consultant.WithNotificationEvents(consultant.NotificationEventTestPush))
+	bn.AttachNotificationHandler("sources", func(n consultant.Notification) {
+		if n.Source != consultant.NotificationSourceTest {
+			t.Logf("sources recipient saw unexpected source %s", n.Source)
+			t.Fail()
+		}
+		atomic.AddUint64(&srcCnt, 1)
+		wg.Done()
+	}, consultant.WithNotificationSources(consultant.NotificationSourceTest))
+	bn.AttachNotificationHandler("filter", func(n consultant.Notification) {
+		atomic.AddUint64(&fnCnt, 1)
+		wg.Done()
+	},
+		consultant.WithNotificationEvents(consultant.NotificationEventTestPush, consultant.NotificationEventManualPush),
+		consultant.WithNotificationFilter(func(n consultant.Notification) bool { return n.Data == "yes" }),
+	)
+	bn.AttachNotificationHandler("all", func(n consultant.Notification) {
+		atomic.AddUint64(&allCnt, 1)
+		wg.Done()
+	})
+
+	bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, "no")
+	bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, "yes")
+	bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, "no")
+	bn.Push(consultant.NotificationSourceCandidate, consultant.NotificationEventManualPush, "no")
+
+	wg.Wait()
+
+	// allow any erroneously delivered notifications to land
+	time.Sleep(100 * time.Millisecond)
+
+	for name, exp := range map[string][2]uint64{
+		"events":  {3, atomic.LoadUint64(&evCnt)},
+		"sources": {3, atomic.LoadUint64(&srcCnt)},
+		"filter":  {1, atomic.LoadUint64(&fnCnt)},
+		"all":     {4, atomic.LoadUint64(&allCnt)},
+	} {
+		if exp[0] != exp[1] {
+			t.Logf("Expected %q to receive %d notifications, saw %d", name, exp[0], exp[1])
+			t.Fail()
+		}
+	}
+}
+
+func TestNotifierBase_OverflowPolicy(t *testing.T) {
+	policyTests := map[consultant.NotificationOverflowPolicy]struct {
+		expected []int
+		dropped  uint64
+	}{
+		consultant.NotificationOverflowDropNewest: {expected: []int{1, 2, 3, 4}, dropped: 1},
+		consultant.NotificationOverflowDropOldest: {expected: []int{1, 2, 4, 5}, dropped: 1},
+		consultant.NotificationOverflowBlock:      {expected: []int{1, 2, 3, 4, 5}, dropped: 0},
+		consultant.NotificationOverflowCoalesce:   {expected: []int{1, 2, 5}, dropped: 2},
+	}
+	for policy, setup := range policyTests {
+		t.Run(policy.String(), func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				mu       sync.Mutex
+				received []int
+
+				entered = make(chan struct{}, 1)
+				gate    = make(chan struct{})
+				pushed  = make(chan struct{})
+				bn      = consultant.NewBasicNotifier(log.New(os.Stdout, "==> Notifier ", log.LstdFlags), true)
+			)
+
+			defer bn.DetachAllNotificationRecipients(false)
+
+			id, _ := bn.AttachNotificationHandler("", func(n consultant.Notification) {
+				select {
+				case entered <- struct{}{}:
+				default:
+				}
+				<-gate
+				mu.Lock()
+				received = append(received, n.Data.(int))
+				mu.Unlock()
+			},
+				consultant.WithNotificationBuffer(2, policy),
+				consultant.WithNotificationBlockTimeout(2*time.Second),
+			)
+
+			// first notification is held by the handler, second by the publisher
+			bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, 1)
+			<-entered
+			bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, 2)
+			time.Sleep(100 * time.Millisecond)
+
+			// remaining fill the buffer and overflow it
+			go func() {
+				for i := 3; i <= 5; i++ {
+					bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, i)
+				}
+				close(pushed)
+			}()
+
+			if policy != consultant.NotificationOverflowBlock {
+				<-pushed
+			} else {
+				time.Sleep(100 * time.Millisecond)
+			}
+			close(gate)
+			<-pushed
+
+			for i := 0; i < 20; i++ {
+				mu.Lock()
+				l := len(received)
+				mu.Unlock()
+				if l >= len(setup.expected) {
+					break
+				}
+				time.Sleep(50 * time.Millisecond)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(received) != len(setup.expected) {
+				t.Fatalf("Expected to receive %v, saw %v", setup.expected, received)
+			}
+			for i, v := range setup.expected {
+				if received[i] != v {
+					t.Logf("Expected to receive %v, saw %v", setup.expected, received)
+					t.Fail()
+					break
+				}
+			}
+			if dropped, ok := bn.NotificationRecipientDropped(id); !ok || dropped != setup.dropped {
+				t.Logf("Expected %d dropped, saw %d (%t)", setup.dropped, dropped, ok)
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestNotifierBase_BlockedPush(t *testing.T) {
+	var (
+		gate    = make(chan struct{})
+		entered = make(chan struct{}, 1)
+		pushed  = make(chan struct{})
+		bn      = consultant.NewBasicNotifier(log.New(os.Stdout, "==> Notifier ", log.LstdFlags), true)
+	)
+
+	defer bn.DetachAllNotificationRecipients(false)
+
+	bn.AttachNotificationHandler("slow", func(n consultant.Notification) {
+		select {
+		case entered <- struct{}{}:
+		default:
+		}
+		<-gate
+	},
+		consultant.WithNotificationBuffer(1, consultant.NotificationOverflowBlock),
+		consultant.WithNotificationBlockTimeout(10*time.Second),
+	)
+
+	// first notification is held by the handler, second by the publisher, and third by the buffer
+	bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, 1)
+	<-entered
+	bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, 2)
+	time.Sleep(100 * time.Millisecond)
+	bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, 3)
+
+	// fourth blocks waiting for room
+	go func() {
+		bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, 4)
+		close(pushed)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// attaching and detaching other recipients must not wait on the blocked push
+	done := make(chan struct{})
+	go func() {
+		bn.AttachNotificationHandler("other", func(consultant.Notification) {})
+		bn.DetachNotificationRecipient("other")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Log("Expected attach and detach to complete while a push is blocked")
+		t.Fail()
+	}
+
+	close(gate)
+	select {
+	case <-pushed:
+	case <-time.After(5 * time.Second):
+		t.Log("Expected blocked push to complete once the handler was released")
+		t.Fail()
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	t.Run("channel", func(t *testing.T) {
+		t.Parallel()
+
+		bn := consultant.NewBasicNotifier(log.New(os.Stdout, "==> Notifier ", log.LstdFlags), true)
+		defer bn.DetachAllNotificationRecipients(false)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		sub := consultant.Subscribe[string](ctx, bn, consultant.NotificationEventTestPush)
+
+		bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventManualPush, "filtered")
+		bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, 42)
+		bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, "hello there")
+
+		select {
+		case n := <-sub.C:
+			if n.Data != "hello there" {
+				t.Logf("Expected %q, saw %q", "hello there", n.Data)
+				t.Fail()
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected typed notification within 5 seconds")
+		}
+
+		if cnt := sub.Mismatches(); cnt != 1 {
+			t.Logf("Expected 1 mismatch, saw %d", cnt)
+			t.Fail()
+		}
+		var merr *consultant.NotificationTypeMismatchError
+		if err := sub.Err(); !errors.As(err, &merr) || merr.Expected != "string" {
+			t.Logf("Expected mismatch error for string, saw %v", err)
+			t.Fail()
+		}
+
+		cancel()
+
+		select {
+		case <-sub.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected subscription to end within 5 seconds of cancel")
+		}
+		if _, ok := <-sub.C; ok {
+			t.Log("Expected C to be closed")
+			t.Fail()
+		}
+		if _, ok := bn.NotificationRecipientDropped(sub.ID()); ok {
+			t.Log("Expected recipient to have been detached")
+			t.Fail()
+		}
+	})
+
+	t.Run("func", func(t *testing.T) {
+		t.Parallel()
+
+		bn := consultant.NewBasicNotifier(log.New(os.Stdout, "==> Notifier ", log.LstdFlags), true)
+		defer bn.DetachAllNotificationRecipients(false)
+
+		seen := make(chan int, 1)
+		sub := consultant.SubscribeFunc[int](context.Background(), bn, func(n consultant.TypedNotification[int]) {
+			seen <- n.Data
+		})
+		defer sub.Unsubscribe()
+
+		bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, 42)
+
+		select {
+		case v := <-seen:
+			if v != 42 {
+				t.Logf("Expected 42, saw %d", v)
+				t.Fail()
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected typed notification within 5 seconds")
+		}
+	})
+}
+
+func TestNotifierBase_Context(t *testing.T) {
+	t.Run("channel", func(t *testing.T) {
+		t.Parallel()
+
+		bn := consultant.NewBasicNotifier(log.New(os.Stdout, "==> Notifier ", log.LstdFlags), true)
+		defer bn.DetachAllNotificationRecipients(false)
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		// nothing will ever read from this channel
+		ch := make(consultant.NotificationChannel)
+		id := bn.AttachNotificationChannelContext(ctx, ch)
+
+		bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, 1)
+		bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, 2)
+
+		cancel()
+
+		for i := 0; i < 20; i++ {
+			if _, ok := bn.NotificationRecipientDropped(id); !ok {
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		if _, ok := bn.NotificationRecipientDropped(id); ok {
+			t.Log("Expected recipient to be detached after context cancel")
+			t.Fail()
+		}
+
+		waitCtx, waitCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer waitCancel()
+		if _, err := bn.DetachAllNotificationRecipientsContext(waitCtx); err != nil {
+			t.Logf("Expected blocked channel recipient to have drained, saw %v", err)
+			t.Fail()
+		}
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		t.Parallel()
+
+		var cnt uint64
+
+		bn := consultant.NewBasicNotifier(log.New(os.Stdout, "==> Notifier ", log.LstdFlags), true)
+		defer bn.DetachAllNotificationRecipients(false)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		id := bn.AttachNotificationHandlerContext(ctx, func(n consultant.Notification) {
+			atomic.AddUint64(&cnt, 1)
+		})
+
+		cancel()
+		time.Sleep(100 * time.Millisecond)
+
+		if ok := bn.DetachNotificationRecipient(id); ok {
+			t.Log("Expected recipient to be detached after context cancel")
+			t.Fail()
+		}
+		bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, 1)
+		time.Sleep(100 * time.Millisecond)
+		if v := atomic.LoadUint64(&cnt); v != 0 {
+			t.Logf("Expected 0 notifications after detach, saw %d", v)
+			t.Fail()
+		}
+	})
+
+	t.Run("detach-all-deadline", func(t *testing.T) {
+		t.Parallel()
+
+		bn := consultant.NewBasicNotifier(log.New(os.Stdout, "==> Notifier ", log.LstdFlags), true)
+
+		entered := make(chan struct{})
+		bn.AttachNotificationHandler("", func(n consultant.Notification) {
+			close(entered)
+			time.Sleep(2 * time.Second)
+		})
+		bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, 1)
+		<-entered
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		cnt, err := bn.DetachAllNotificationRecipientsContext(ctx)
+		if cnt != 1 {
+			t.Logf("Expected 1 detached, saw %d", cnt)
+			t.Fail()
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Logf("Expected %v, saw %v", context.DeadlineExceeded, err)
+			t.Fail()
+		}
+	})
+}
+
+func TestNotifierBase_Replay(t *testing.T) {
+	bn := consultant.NewBasicNotifier(log.New(os.Stdout, "==> Notifier ", log.LstdFlags), true)
+	defer bn.DetachAllNotificationRecipients(false)
+
+	bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, 1)
+	bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventManualPush, 2)
+	bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, 3)
+
+	if n, ok := bn.LastNotification(consultant.NotificationSourceTest, consultant.NotificationEventTestPush); !ok || n.Data != 3 {
+		t.Logf("Expected last test push to have data 3, saw %v (%t)", n.Data, ok)
+		t.Fail()
+	}
+
+	replayed := make(consultant.NotificationChannel, 10)
+	bn.AttachNotificationChannel("replay", replayed, consultant.WithNotificationReplay())
+
+	filtered := make(consultant.NotificationChannel, 10)
+	bn.AttachNotificationChannel(
+		"filtered",
+		filtered,
+		consultant.WithNotificationReplay(),
+		consultant.WithNotificationEvents(consultant.NotificationEventTestPush),
+	)
+
+	none := make(consultant.NotificationChannel, 10)
+	bn.AttachNotificationChannel("none", none)
+
+	bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, 4)
+
+	collect := func(ch consultant.NotificationChannel, cnt int) []interface{} {
+		out := make([]interface{}, 0, cnt)
+		for len(out) < cnt {
+			select {
+			case n := <-ch:
+				out = append(out, n.Data)
+			case <-time.After(5 * time.Second):
+				return out
+			}
+		}
+		return out
+	}
+
+	for name, setup := range map[string]struct {
+		ch       consultant.NotificationChannel
+		expected []interface{}
+	}{
+		"replay":   {replayed, []interface{}{2, 3, 4}},
+		"filtered": {filtered, []interface{}{3, 4}},
+		"none":     {none, []interface{}{4}},
+	} {
+		seen := collect(setup.ch, len(setup.expected))
+		if len(seen) != len(setup.expected) {
+			t.Logf("%s: expected %v, saw %v", name, setup.expected, seen)
+			t.Fail()
+			continue
+		}
+		for i := range seen {
+			if seen[i] != setup.expected[i] {
+				t.Logf("%s: expected %v, saw %v", name, setup.expected, seen)
+				t.Fail()
+				break
+			}
+		}
+	}
+}
+
+func TestNotifierBase_Sync(t *testing.T) {
+	t.Run("handled", func(t *testing.T) {
+		t.Parallel()
+
+		var handled uint64
+
+		bn := consultant.NewBasicNotifier(log.New(os.Stdout, "==> Notifier ", log.LstdFlags), true)
+		defer bn.DetachAllNotificationRecipients(false)
+
+		bn.AttachNotificationHandler("", func(n consultant.Notification) {
+			time.Sleep(200 * time.Millisecond)
+			atomic.AddUint64(&handled, 1)
+		}, consultant.WithNotificationSync(5*time.Second, consultant.NotificationEventTestPush))
+
+		bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventManualPush, nil)
+		bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, nil)
+
+		// the asynchronous notification is queued ahead of the synchronous one, so both must have been handled
+		if v := atomic.LoadUint64(&handled); v != 2 {
+			t.Logf("Expected 2 notifications handled once Push returned, saw %d", v)
+			t.Fail()
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		t.Parallel()
+
+		bn := consultant.NewBasicNotifier(log.New(os.Stdout, "==> Notifier ", log.LstdFlags), true)
+		defer bn.DetachAllNotificationRecipients(false)
+
+		bn.AttachNotificationHandler("", func(n consultant.Notification) {
+			time.Sleep(2 * time.Second)
+		}, consultant.WithNotificationSync(100*time.Millisecond, consultant.NotificationEventTestPush))
+
+		start := time.Now()
+		bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, nil)
+		if d := time.Since(start); d > time.Second {
+			t.Logf("Expected Push to return after sync timeout, took %s", d)
+			t.Fail()
+		}
+	})
+}
+
+func TestNotifierBase_Sequence(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		seqs []uint64
+
+		entered = make(chan struct{}, 1)
+		gate    = make(chan struct{})
+		bn      = consultant.NewBasicNotifier(log.New(os.Stdout, "==> Notifier ", log.LstdFlags), true)
+	)
+
+	defer bn.DetachAllNotificationRecipients(false)
+
+	id, _ := bn.AttachNotificationHandler("", func(n consultant.Notification) {
+		select {
+		case entered <- struct{}{}:
+		default:
+		}
+		<-gate
+		mu.Lock()
+		seqs = append(seqs, n.Sequence)
+		mu.Unlock()
+	}, consultant.WithNotificationBuffer(1, consultant.NotificationOverflowDropNewest))
+
+	bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, nil)
+	<-entered
+	bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, nil)
+	time.Sleep(100 * time.Millisecond)
+
+	// 3 fills the buffer, 4 and 5 are dropped
+	for i := 0; i < 3; i++ {
+		bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, nil)
+	}
+	close(gate)
+	time.Sleep(100 * time.Millisecond)
+	bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, nil)
+
+	for i := 0; i < 20; i++ {
+		mu.Lock()
+		l := len(seqs)
+		mu.Unlock()
+		if l == 4 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := []uint64{1, 2, 3, 6}
+	if len(seqs) != len(expected) {
+		t.Fatalf("Expected sequences %v, saw %v", expected, seqs)
+	}
+	for i := range expected {
+		if seqs[i] != expected[i] {
+			t.Logf("Expected sequences %v, saw %v", expected, seqs)
+			t.Fail()
+			break
+		}
+	}
+	if dropped, _ := bn.NotificationRecipientDropped(id); dropped != 2 {
+		t.Logf("Expected 2 dropped, saw %d", dropped)
+		t.Fail()
+	}
+}
+
+func TestNotifierBase_IDs(t *testing.T) {
+	bn := consultant.NewBasicNotifier(log.New(os.Stdout, "==> Notifier ", log.LstdFlags), true)
+	defer bn.DetachAllNotificationRecipients(false)
+
+	ch := make(consultant.NotificationChannel, 3)
+	bn.AttachNotificationChannel("", ch)
+
+	for i := 0; i < 3; i++ {
+		bn.Push(consultant.NotificationSourceTest, consultant.NotificationEventTestPush, nil)
+	}
+
+	for i := 1; i <= 3; i++ {
+		select {
+		case n := <-ch:
+			if expected := fmt.Sprintf("%s-%d", bn.InstanceID(), i); n.ID != expected {
+				t.Logf("Expected ID %q, saw %q", expected, n.ID)
+				t.Fail()
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected notification within 5 seconds")
+		}
+	}
+
+	if other := consultant.NewBasicNotifier(nil, false); other.InstanceID() == bn.InstanceID() {
+		t.Log("Expected instance IDs to differ between notifiers")
+		t.Fail()
+	}
+}
@@ -0,0 +1,161 @@
+// Package prommetrics provides a consultant.MetricsRecorder that exposes what it records as a prometheus.Collector.
+// It is kept apart from the consultant package so that Prometheus is only a dependency of those that use it.
+package prommetrics
+
+import (
+	"time"
+
+	"github.com/myENA/consultant/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	DefaultNamespace = "consultant"
+)
+
+// Collector is a consultant.MetricsRecorder that exposes what it records as a prometheus.Collector.  A single instance
+// may be shared by any number of managed types, and registered with any registry.
+type Collector struct {
+	sessionRenewDuration  *prometheus.HistogramVec
+	sessionRenewFailures  *prometheus.CounterVec
+	candidateElected      *prometheus.GaugeVec
+	candidateTransitions  *prometheus.CounterVec
+	candidateLeaderTime   *prometheus.HistogramVec
+	serviceRefreshes      *prometheus.CounterVec
+	serviceReRegistration *prometheus.CounterVec
+	notificationDrops     *prometheus.CounterVec
+}
+
+// NewCollector constructs a new Collector.  If namespace is empty, DefaultNamespace is used.
+func NewCollector(namespace string) *Collector {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+
+	pc := new(Collector)
+
+	pc.sessionRenewDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "session",
+		Name:      "renew_duration_seconds",
+		Help:      "Time taken by each attempt to renew an upstream session.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"session"})
+	pc.sessionRenewFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "session",
+		Name:      "renew_failures_total",
+		Help:      "Number of failed attempts to renew an upstream session.",
+	}, []string{"session"})
+
+	pc.candidateElected = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "candidate",
+		Name:      "elected",
+		Help:      "1 if the candidate is currently elected, 0 otherwise.",
+	}, []string{"candidate"})
+	pc.candidateTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "candidate",
+		Name:      "election_transitions_total",
+		Help:      "Number of times the candidate has won or lost an election.",
+	}, []string{"candidate", "result"})
+	pc.candidateLeaderTime = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "candidate",
+		Name:      "leader_duration_seconds",
+		Help:      "Amount of time the candidate remained leader, observed once leadership is lost.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+	}, []string{"candidate"})
+
+	pc.serviceRefreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "service",
+		Name:      "refreshes_total",
+		Help:      "Number of attempts to refresh the local state of a managed service.",
+	}, []string{"service", "result"})
+	pc.serviceReRegistration = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "service",
+		Name:      "reregistrations_total",
+		Help:      "Number of attempts to re-register a managed service that was found missing.",
+	}, []string{"service", "result"})
+
+	pc.notificationDrops = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "notification",
+		Name:      "drops_total",
+		Help:      "Number of notifications dropped for a recipient due to backpressure.",
+	}, []string{"source", "event"})
+
+	return pc
+}
+
+func (pc *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		pc.sessionRenewDuration,
+		pc.sessionRenewFailures,
+		pc.candidateElected,
+		pc.candidateTransitions,
+		pc.candidateLeaderTime,
+		pc.serviceRefreshes,
+		pc.serviceReRegistration,
+		pc.notificationDrops,
+	}
+}
+
+// Describe implements prometheus.Collector
+func (pc *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range pc.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector
+func (pc *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range pc.collectors() {
+		c.Collect(ch)
+	}
+}
+
+// SessionRenewed implements consultant.MetricsRecorder
+func (pc *Collector) SessionRenewed(sessionName string, took time.Duration, err error) {
+	pc.sessionRenewDuration.WithLabelValues(sessionName).Observe(took.Seconds())
+	if err != nil {
+		pc.sessionRenewFailures.WithLabelValues(sessionName).Inc()
+	}
+}
+
+// CandidateElectionChanged implements consultant.MetricsRecorder
+func (pc *Collector) CandidateElectionChanged(candidateID string, elected bool, leaderFor time.Duration) {
+	if elected {
+		pc.candidateElected.WithLabelValues(candidateID).Set(1)
+		pc.candidateTransitions.WithLabelValues(candidateID, "elected").Inc()
+		return
+	}
+	pc.candidateElected.WithLabelValues(candidateID).Set(0)
+	pc.candidateTransitions.WithLabelValues(candidateID, "lost").Inc()
+	pc.candidateLeaderTime.WithLabelValues(candidateID).Observe(leaderFor.Seconds())
+}
+
+// ServiceRefreshed implements consultant.MetricsRecorder
+func (pc *Collector) ServiceRefreshed(serviceID string, err error) {
+	pc.serviceRefreshes.WithLabelValues(serviceID, metricsResult(err)).Inc()
+}
+
+// ServiceReRegistered implements consultant.MetricsRecorder
+func (pc *Collector) ServiceReRegistered(serviceID string, err error) {
+	pc.serviceReRegistration.WithLabelValues(serviceID, metricsResult(err)).Inc()
+}
+
+// NotificationDropped implements consultant.MetricsRecorder
+func (pc *Collector) NotificationDropped(n consultant.Notification) {
+	pc.notificationDrops.WithLabelValues(n.Source.String(), n.Event.String()).Inc()
+}
+
+func metricsResult(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
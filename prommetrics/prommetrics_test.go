@@ -0,0 +1,79 @@
+package prommetrics_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/myENA/consultant/v2"
+	"github.com/myENA/consultant/v2/prommetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const collectorExpected = `
+# HELP consultant_candidate_elected 1 if the candidate is currently elected, 0 otherwise.
+# TYPE consultant_candidate_elected gauge
+consultant_candidate_elected{candidate="candidate"} 0
+# HELP consultant_candidate_election_transitions_total Number of times the candidate has won or lost an election.
+# TYPE consultant_candidate_election_transitions_total counter
+consultant_candidate_election_transitions_total{candidate="candidate",result="elected"} 1
+consultant_candidate_election_transitions_total{candidate="candidate",result="lost"} 1
+# HELP consultant_notification_drops_total Number of notifications dropped for a recipient due to backpressure.
+# TYPE consultant_notification_drops_total counter
+consultant_notification_drops_total{event="CandidateLostElection",source="Candidate"} 1
+# HELP consultant_service_refreshes_total Number of attempts to refresh the local state of a managed service.
+# TYPE consultant_service_refreshes_total counter
+consultant_service_refreshes_total{result="error",service="service"} 1
+consultant_service_refreshes_total{result="success",service="service"} 1
+# HELP consultant_service_reregistrations_total Number of attempts to re-register a managed service that was found missing.
+# TYPE consultant_service_reregistrations_total counter
+consultant_service_reregistrations_total{result="success",service="service"} 1
+# HELP consultant_session_renew_failures_total Number of failed attempts to renew an upstream session.
+# TYPE consultant_session_renew_failures_total counter
+consultant_session_renew_failures_total{session="session"} 1
+`
+
+func TestCollector(t *testing.T) {
+	var _ consultant.MetricsRecorder = new(prommetrics.Collector)
+
+	pc := prommetrics.NewCollector("")
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(pc); err != nil {
+		t.Fatalf("Error registering collector: %s", err)
+	}
+
+	pc.SessionRenewed("session", 10*time.Millisecond, nil)
+	pc.SessionRenewed("session", 20*time.Millisecond, errors.New("nope"))
+	pc.CandidateElectionChanged("candidate", true, 0)
+	pc.CandidateElectionChanged("candidate", false, time.Minute)
+	pc.ServiceRefreshed("service", nil)
+	pc.ServiceRefreshed("service", errors.New("nope"))
+	pc.ServiceReRegistered("service", nil)
+	pc.NotificationDropped(consultant.Notification{
+		Source: consultant.NotificationSourceCandidate,
+		Event:  consultant.NotificationEventCandidateLostElection,
+	})
+
+	err := testutil.GatherAndCompare(
+		reg,
+		strings.NewReader(collectorExpected),
+		"consultant_candidate_elected",
+		"consultant_candidate_election_transitions_total",
+		"consultant_notification_drops_total",
+		"consultant_service_refreshes_total",
+		"consultant_service_reregistrations_total",
+		"consultant_session_renew_failures_total",
+	)
+	if err != nil {
+		t.Logf("Unexpected metrics: %s", err)
+		t.Fail()
+	}
+
+	if cnt := testutil.CollectAndCount(pc, "consultant_session_renew_duration_seconds", "consultant_candidate_leader_duration_seconds"); cnt != 2 {
+		t.Logf("Expected 2 histogram series, saw %d", cnt)
+		t.Fail()
+	}
+}
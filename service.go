@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -13,6 +16,7 @@ import (
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/api/watch"
 	"github.com/myENA/go-helpers"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ManagedServiceState uint8
@@ -37,9 +41,166 @@ func (s ManagedServiceState) String() string {
 	}
 }
 
+// ManagedServiceDrainMode describes how a ManagedService is taken out of rotation before it is deregistered
+type ManagedServiceDrainMode uint8
+
+const (
+	// ManagedServiceDrainModeNone will deregister the service immediately
+	ManagedServiceDrainModeNone ManagedServiceDrainMode = iota
+	// ManagedServiceDrainModeMaintenance will place the service into maintenance mode before waiting
+	ManagedServiceDrainModeMaintenance
+	// ManagedServiceDrainModeCritical will register a critical check against the service before waiting
+	ManagedServiceDrainModeCritical
+)
+
+func (m ManagedServiceDrainMode) String() string {
+	switch m {
+	case ManagedServiceDrainModeNone:
+		return "none"
+	case ManagedServiceDrainModeMaintenance:
+		return "maintenance"
+	case ManagedServiceDrainModeCritical:
+		return "critical"
+
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ManagedServiceHealingAction describes the action taken by a ManagedServiceHealingRule
+type ManagedServiceHealingAction uint8
+
+const (
+	// ManagedServiceHealingActionHook will call the rule's Hook
+	ManagedServiceHealingActionHook ManagedServiceHealingAction = iota + 1
+	// ManagedServiceHealingActionReRegisterCheck will re-register the critical check with the agent, resetting it
+	ManagedServiceHealingActionReRegisterCheck
+	// ManagedServiceHealingActionExit will exit the process
+	ManagedServiceHealingActionExit
+)
+
+func (a ManagedServiceHealingAction) String() string {
+	switch a {
+	case ManagedServiceHealingActionHook:
+		return "hook"
+	case ManagedServiceHealingActionReRegisterCheck:
+		return "re-register-check"
+	case ManagedServiceHealingActionExit:
+		return "exit"
+
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ManagedServiceHealingHook is called by a ManagedServiceHealingRule with ManagedServiceHealingActionHook.  It is
+// called in its own goroutine, and may safely call any method of the ManagedService.
+type ManagedServiceHealingHook func(ctx context.Context, ms *ManagedService, checkID string) error
+
+// ManagedServiceHealingRule describes an action to take once a check registered to a ManagedService has been critical
+// for a period of time.  Rules are evaluated after every refresh of the service, so the action will be taken no more
+// than one refresh interval after the period has elapsed.
+type ManagedServiceHealingRule struct {
+	// CheckID [optional]
+	//
+	// ID of the check this rule applies to.  If empty, the rule applies to every check registered to the service, with
+	// the exception of maintenance and drain checks.
+	CheckID string
+
+	// After [required]
+	//
+	// How long the check must have been continuously critical before the action is taken
+	After time.Duration
+
+	// Action [required]
+	//
+	// The action to take
+	Action ManagedServiceHealingAction
+
+	// Repeat [optional]
+	//
+	// If greater than zero, the action will be taken again each time this much time has passed while the check remains
+	// critical.  Otherwise, the action is taken once each time the check becomes critical.
+	Repeat time.Duration
+
+	// Hook [optional]
+	//
+	// Required if Action is ManagedServiceHealingActionHook
+	Hook ManagedServiceHealingHook
+
+	// ExitCode [optional]
+	//
+	// Exit code used with ManagedServiceHealingActionExit.  Defaults to 1.
+	ExitCode int
+
+	// Exit [optional]
+	//
+	// Function called with ManagedServiceHealingActionExit.  Defaults to os.Exit.
+	Exit func(code int)
+
+	// ExitNotificationTimeout [optional]
+	//
+	// With ManagedServiceHealingActionExit, the maximum amount of time to wait for every recipient to handle the
+	// healing notification before Exit is called.  Defaults to ServiceDefaultHealingExitNotificationTimeout.
+	ExitNotificationTimeout time.Duration
+}
+
+// ManagedServiceHealing describes a healing action taken by a ManagedService
+type ManagedServiceHealing struct {
+	Action        ManagedServiceHealingAction `json:"action"`
+	CheckID       string                      `json:"check_id"`
+	CriticalSince time.Time                   `json:"critical_since"`
+}
+
 const (
-	ServiceDefaultIDFormat        = SlugName + "-" + SlugAddr + "-" + SlugRand
-	ServiceDefaultRefreshInterval = api.ReadableDuration(30 * time.Second)
+	ServiceDefaultIDFormat         = SlugName + "-" + SlugAddr + "-" + SlugRand
+	ServiceDefaultRefreshInterval  = api.ReadableDuration(30 * time.Second)
+	ServiceDefaultDrainGracePeriod = 10 * time.Second
+
+	// ServiceDefaultDeregisterCriticalServiceAfter is applied to every check registered by a ManagedService that does
+	// not define its own DeregisterCriticalServiceAfter value, ensuring the registration of a process that died without
+	// deregistering is eventually removed
+	ServiceDefaultDeregisterCriticalServiceAfter = 10 * time.Minute
+
+	// ServiceDefaultBootstrapRetryInterval is how often registration of a bootstrap definition is retried while the
+	// agent is unreachable
+	ServiceDefaultBootstrapRetryInterval = 2 * time.Second
+
+	// ServiceDefaultHealingExitNotificationTimeout is the maximum amount of time an exit healing action waits for its
+	// notification to be handled before exiting
+	ServiceDefaultHealingExitNotificationTimeout = 5 * time.Second
+
+	// ServiceDefaultSweepOrphansConfirmWindow is the minimum amount of time a registration must be seen to remain
+	// critical before SweepOrphans considers it orphaned
+	ServiceDefaultSweepOrphansConfirmWindow = 30 * time.Second
+
+	// ServiceDrainCheckIDPrefix is prepended to the service's id to form the id of the critical check registered when
+	// draining with ManagedServiceDrainModeCritical
+	ServiceDrainCheckIDPrefix = "_service_drain:"
+
+	// ServiceSidecarIDSuffix is appended to the service's id by the agent to form the id of a sidecar service registered
+	// without one
+	ServiceSidecarIDSuffix = "-sidecar-proxy"
+
+	// ServiceDrainReason is used as the maintenance reason when draining with ManagedServiceDrainModeMaintenance
+	ServiceDrainReason = "managed service is draining"
+
+	// ServiceMutateTagsAttempts is the maximum number of times a tag mutation will be attempted when a conflicting
+	// modification is seen
+	ServiceMutateTagsAttempts = 5
+
+	serviceDrainPollInterval = time.Second
+)
+
+var (
+	// ErrServiceTagsConflict is returned when a tag mutation could not be applied due to the service being concurrently
+	// modified before the mutation was written
+	ErrServiceTagsConflict = errors.New("service was modified during tag mutation")
+
+	// ErrServiceTagsOverwritten is returned when the tags read back after a mutation was written do not match the
+	// mutation, meaning something else wrote to the service at the same time.  Mutations are not retried once this has
+	// been seen, as the other write may have been lost.
+	ErrServiceTagsOverwritten = errors.New("service was overwritten after tag mutation was written")
 )
 
 // ManagedServiceUpdate is the value of .Data in all Notification pushes from a ManagedService
@@ -47,24 +208,170 @@ type ManagedServiceUpdate struct {
 	ServiceID     string    `json:"service_id"`
 	ServiceName   string    `json:"service_name"`
 	LastRefreshed time.Time `json:"last_refreshed"`
+	Maintenance   bool      `json:"maintenance"`
+	MaintReason   string    `json:"maint_reason"`
+	Health        string    `json:"health"`
 	Error         error     `json:"error"`
+
+	// PreviousHealth and HealthChanges are only populated on NotificationEventManagedServiceHealthChanged
+	// notifications, and contain the aggregate health before the transition and every check whose status changed
+	PreviousHealth string                      `json:"previous_health,omitempty"`
+	HealthChanges  []ManagedServiceCheckChange `json:"health_changes,omitempty"`
+
+	// OrphanID is only populated on NotificationEventManagedServiceOrphanSwept notifications, and contains the
+	// id of the orphaned service that was deregistered
+	OrphanID string `json:"orphan_id,omitempty"`
+
+	// Healing is only populated on NotificationEventManagedServiceHealingAction notifications.  Error will contain the
+	// error returned by the action, if any.
+	Healing *ManagedServiceHealing `json:"healing,omitempty"`
+
+	// Drift is only populated on NotificationEventManagedServiceDrift notifications
+	Drift []ManagedServiceFieldDrift `json:"drift,omitempty"`
+
+	// PreviousTags and Tags are only populated on tag mutation notifications, and contain the service's tags before and
+	// after the mutation attempt
+	PreviousTags []string `json:"previous_tags,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+}
+
+// ManagedServiceCheckChange describes a change in status of a single check registered to a ManagedService.  A check that
+// has newly appeared will have an empty PreviousStatus, and one that has been removed will have an empty Status.
+type ManagedServiceCheckChange struct {
+	CheckID        string `json:"check_id"`
+	Name           string `json:"name"`
+	PreviousStatus string `json:"previous_status"`
+	Status         string `json:"status"`
+	Output         string `json:"output"`
+}
+
+// managedServiceCheckStatus is the last seen status of a single check
+type managedServiceCheckStatus struct {
+	name   string
+	status string
+	output string
+
+	// criticalSince is the time at which the check was first seen as critical, and is zero if it is not
+	criticalSince time.Time
+}
+
+// ManagedServiceFieldDrift describes a single field of a service registration that was found to differ from its desired
+// value
+type ManagedServiceFieldDrift struct {
+	Field    string `json:"field"`
+	Observed string `json:"observed"`
+	Desired  string `json:"desired"`
+}
+
+// ManagedServiceDesiredState describes the registration values a ManagedService will enforce.  Zero-valued fields
+// are not enforced.  Any difference seen between these values and the registration on the agent is corrected on the
+// next refresh.
+type ManagedServiceDesiredState struct {
+	// Address, if not empty, is the address the service must be registered with
+	Address string `json:"address"`
+
+	// Port, if not 0, is the port the service must be registered with
+	Port int `json:"port"`
+
+	// Meta, if not nil, is the exact set of meta values the service must be registered with
+	Meta map[string]string `json:"meta"`
+
+	// Weights, if not nil, are the weights the service must be registered with
+	Weights *api.AgentWeights `json:"weights"`
+}
+
+func (ds *ManagedServiceDesiredState) clone() *ManagedServiceDesiredState {
+	if ds == nil {
+		return nil
+	}
+	out := new(ManagedServiceDesiredState)
+	out.Address = ds.Address
+	out.Port = ds.Port
+	if ds.Meta != nil {
+		out.Meta = make(map[string]string, len(ds.Meta))
+		for k, v := range ds.Meta {
+			out.Meta[k] = v
+		}
+	}
+	if ds.Weights != nil {
+		out.Weights = new(api.AgentWeights)
+		*out.Weights = *ds.Weights
+	}
+	return out
+}
+
+// drift returns the list of fields in which the provided service differs from this desired state
+func (ds *ManagedServiceDesiredState) drift(svc *api.AgentService) []ManagedServiceFieldDrift {
+	var drift []ManagedServiceFieldDrift
+	if ds.Address != "" && ds.Address != svc.Address {
+		drift = append(drift, ManagedServiceFieldDrift{Field: "Address", Observed: svc.Address, Desired: ds.Address})
+	}
+	if ds.Port != 0 && ds.Port != svc.Port {
+		drift = append(drift, ManagedServiceFieldDrift{Field: "Port", Observed: strconv.Itoa(svc.Port), Desired: strconv.Itoa(ds.Port)})
+	}
+	if ds.Meta != nil && !strMapsEqual(ds.Meta, svc.Meta) {
+		drift = append(drift, ManagedServiceFieldDrift{Field: "Meta", Observed: fmt.Sprintf("%v", svc.Meta), Desired: fmt.Sprintf("%v", ds.Meta)})
+	}
+	if ds.Weights != nil && *ds.Weights != svc.Weights {
+		drift = append(drift, ManagedServiceFieldDrift{Field: "Weights", Observed: fmt.Sprintf("%+v", svc.Weights), Desired: fmt.Sprintf("%+v", *ds.Weights)})
+	}
+	return drift
+}
+
+// apply overwrites the relevant fields of the provided registration with desired values
+func (ds *ManagedServiceDesiredState) apply(reg *api.AgentServiceRegistration) {
+	if ds.Address != "" {
+		reg.Address = ds.Address
+	}
+	if ds.Port != 0 {
+		reg.Port = ds.Port
+	}
+	if ds.Meta != nil {
+		reg.Meta = ds.Meta
+	}
+	if ds.Weights != nil {
+		reg.Weights = ds.Weights
+	}
 }
 
 // ManagedServiceConfig describes the basis for a new ManagedService instance
 type ManagedServiceConfig struct {
 	// ID [required]
 	//
-	// ID of service to fetch to turn into a managed service
+	// ID of service to fetch to turn into a managed service.  May be left empty if Registration is defined, in which
+	// case the registration's ID is used, or one is generated using ServiceDefaultIDFormat if it also has none.
 	ID string
 
+	// Registration [optional]
+	//
+	// Full bootstrap definition of the service.  If defined, the service need not exist when the managed service is
+	// constructed: it will be registered by the managed service itself, and should the agent be unreachable at that
+	// time, registration will be retried every BootstrapRetryInterval once running.  This is copied at construction.
+	//
+	// Only transport errors and 5xx responses are retried.  Should the agent otherwise reject the registration, such as
+	// with a 400 or 403, construction fails, or if first seen once running, NotificationEventManagedServiceBootstrapFailed
+	// is pushed and no further attempt is made.
+	//
+	// Unless otherwise configured, BaseChecks and SidecarService default to the checks and sidecar defined by the
+	// registration.
+	Registration *api.AgentServiceRegistration
+
+	// BootstrapRetryInterval [optional]
+	//
+	// How often to retry registration of the bootstrap definition while the agent is unreachable.  Defaults to value
+	// of ServiceDefaultBootstrapRetryInterval.  Has no effect if Registration is not defined.
+	BootstrapRetryInterval time.Duration
+
 	// BaseChecks [optional] (recommended)
 	//
 	// These are the base service checks that will be re-registered with the service should it be removed externally
 	// from the node the service was registered to.
 	//
-	// This will most likely be removed in the future once https://github.com/hashicorp/consul/issues/1680 is finally
-	// implemented, but for now is necessary if you wish for your service to automatically have its health checks
-	// re-registered
+	// The full set of checks registered to the service is snapshotted from the agent on every refresh, and it is that
+	// snapshot that is used when re-registering.  The agent does not expose every attribute of a check however (TTL,
+	// script args, docker container, and alias targets, for example), so the definitions provided here are used to
+	// fill in those gaps.  A check that cannot be fully rebuilt from the agent and has no matching base check will not
+	// be re-registered.
 	BaseChecks api.AgentServiceChecks
 
 	// RefreshInterval [optional]
@@ -97,11 +404,99 @@ type ManagedServiceConfig struct {
 	// If true, will enable debug-level logging if a logger is provided
 	Debug bool
 
+	// StructuredLogger [optional]
+	//
+	// Optionally specify a levelled, structured logger to use in place of Logger.  If defined, Logger and Debug are
+	// ignored.
+	StructuredLogger StructuredLogger
+
 	// Client [optional]
 	//
 	// Optionally provide a Consul client instance to use.  If one is not defined, a new one will be created with
 	// default configuration values.
 	Client *api.Client
+
+	// DrainMode [optional]
+	//
+	// If set to something other than ManagedServiceDrainModeNone, the service will be taken out of rotation and given
+	// time to drain before being deregistered by Deregister() or Shutdown().
+	DrainMode ManagedServiceDrainMode
+
+	// DrainGracePeriod [optional]
+	//
+	// Maximum amount of time to wait between taking the service out of rotation and deregistering it.  Defaults to
+	// value of ServiceDefaultDrainGracePeriod.  Has no effect if DrainMode is ManagedServiceDrainModeNone.
+	DrainGracePeriod time.Duration
+
+	// DrainWaitForHealth [optional]
+	//
+	// If true, the drain wait will end as soon as the service is no longer seen as passing by the health endpoint,
+	// rather than always waiting for the full DrainGracePeriod.
+	DrainWaitForHealth bool
+
+	// DesiredState [optional]
+	//
+	// If defined, the service registration will be checked against these values on every refresh and re-registered
+	// should any of them have drifted.  This is copied at construction.
+	DesiredState *ManagedServiceDesiredState
+
+	// WatchCatalog [optional]
+	//
+	// The service always watches its own registration on the local agent.  If true, the service's entries on the
+	// health endpoint will additionally be watched by service name, triggering a refresh whenever any instance of the
+	// service changes.
+	WatchCatalog bool
+
+	// SidecarService [optional]
+	//
+	// Definition of the Connect sidecar proxy registered alongside the service.  It is sent with every re-registration
+	// of the service, and the service will be re-registered should the sidecar go missing from the agent.  This is
+	// copied at construction.
+	SidecarService *api.AgentServiceRegistration
+
+	// DeregisterCriticalServiceAfter [optional]
+	//
+	// Applied to every check registered by the managed service that does not define its own value.  Defaults to value
+	// of ServiceDefaultDeregisterCriticalServiceAfter.  Set to a negative value to disable.
+	DeregisterCriticalServiceAfter time.Duration
+
+	// SweepOrphans [optional]
+	//
+	// If true, SweepOrphans will be called in the background once the service has been found on the agent, either
+	// during construction or once a bootstrap registration succeeds, deregistering earlier instances of this service
+	// left behind by processes that died without deregistering.
+	SweepOrphans bool
+
+	// SweepOrphansConfirmWindow [optional]
+	//
+	// Minimum amount of time a registration must remain critical before SweepOrphans considers it orphaned.  The window
+	// is extended to the longest interval of the registration's checks, if greater.  Defaults to value of
+	// ServiceDefaultSweepOrphansConfirmWindow.
+	SweepOrphansConfirmWindow time.Duration
+
+	// HealingRules [optional]
+	//
+	// Actions to take once checks registered to the service have been critical for a period of time.  This is copied at
+	// construction.
+	HealingRules []ManagedServiceHealingRule
+
+	// NotificationBus [optional]
+	//
+	// If defined, the service will register itself with this bus on construction, using its service ID as producer
+	// identity.  Should that identity already be registered, a random suffix is appended.
+	NotificationBus *NotificationBus
+
+	// Metrics [optional]
+	//
+	// If defined, refreshes, re-registrations, and dropped notifications will be recorded here
+	Metrics MetricsRecorder
+
+	// TracerProvider [optional]
+	//
+	// If defined, calls made to the Consul agent to register and deregister the service and to fetch its checks will be
+	// wrapped in spans from this provider.  Calls made on behalf of a method accepting a context are children of any span
+	// it carries.
+	TracerProvider trace.TracerProvider
 }
 
 // ManagedService
@@ -112,11 +507,19 @@ type ManagedService struct {
 	*notifierBase
 	mu sync.RWMutex
 
+	// tmu serializes tag mutations made by this managed service, so they never conflict with one another.  it is held
+	// while the mutation func is called, when mu is not.
+	tmu sync.Mutex
+
 	state ManagedServiceState
 
 	serviceID  string
 	baseChecks api.AgentServiceChecks
 
+	// checks is the most recent snapshot of the check definitions registered to the service.  it is updated by
+	// .refreshService() and used in place of baseChecks when re-registering the service.
+	checks api.AgentServiceChecks
+
 	// svc MUST ALWAYS BE DEFINED.  If it cannot be fetched on boot, the service must die.
 	//
 	// it is updated by, and only by, the .refreshService() method, either when forced or via the normal maintenance
@@ -128,15 +531,60 @@ type ManagedService struct {
 	localRefreshed  time.Time
 	forceRefresh    chan chan error
 
+	// maint and maintReason track the maintenance mode state requested via .EnableMaintenance() and
+	// .DisableMaintenance(), and are updated from the agent's maintenance check by .snapshotChecks() so that changes
+	// made outside this process are seen.  they are used to put the service back into maintenance mode should it need
+	// to be re-registered.
+	maint       bool
+	maintReason string
+
+	drainMode          ManagedServiceDrainMode
+	drainGrace         time.Duration
+	drainWaitForHealth bool
+
+	// health is the aggregate status of all checks registered to the service, and checkStatus the last seen status of
+	// each individual check.  both are updated by .snapshotChecks().
+	health      string
+	checkStatus map[string]managedServiceCheckStatus
+
+	// healingRules are evaluated after every refresh performed by the maintenance loop.  healingFired records the last
+	// time each rule fired for each check, keyed by rule index and check id.
+	healingRules []ManagedServiceHealingRule
+	healingFired map[managedServiceHealingKey]time.Time
+
+	// bootstrapping is true while a bootstrap registration has yet to be seen on the agent.  while true, ms.svc is
+	// synthesized from the bootstrap registration and refreshes are retried every bootstrapRetry.
+	bootstrapping  bool
+	bootstrapRetry time.Duration
+
+	// bootstrapErr, if set, is the permanent error with which the agent rejected the bootstrap registration.  no
+	// further registration attempts are made once set.
+	bootstrapErr error
+
+	// deregisterAfter is applied to every check registered that does not define its own DeregisterCriticalServiceAfter
+	// value.  empty if disabled.
+	deregisterAfter string
+
+	// sweepOrphans, if true, causes SweepOrphans to be run in the background once the service has first been seen on
+	// the agent.  sweepWindow is the minimum confirmation window used by SweepOrphans.
+	sweepOrphans bool
+	sweepWindow  time.Duration
+
+	// desired, if defined, is enforced on every refresh
+	desired *ManagedServiceDesiredState
+
+	watchCatalog bool
+
+	// sidecar, if defined, is registered alongside the service every time it is re-registered
+	sidecar *api.AgentServiceRegistration
+
 	client *api.Client
 	qo     *api.QueryOptions
 	wo     *api.WriteOptions
 	rttl   time.Duration
+	tracer trace.Tracer
 
 	stop chan chan error
-
-	dbg    bool
-	logger Logger
 }
 
 // NewManagedService creates a new ManagedService instance.
@@ -147,30 +595,93 @@ func NewManagedService(cfg *ManagedServiceConfig) (*ManagedService, error) {
 		ms = new(ManagedService)
 	)
 
-	// maybe logger
-	ms.dbg = cfg.Debug
-	ms.logger = cfg.Logger
-
-	ms.notifierBase = newNotifierBase(ms.logger, ms.dbg)
-
 	if cfg == nil {
 		return nil, errors.New("cfg cannot be nil")
 	}
-	if cfg.ID == "" {
+
+	ms.notifierBase = newNotifierBase(buildStructuredLogger(
+		cfg.StructuredLogger,
+		cfg.Logger,
+		cfg.Debug,
+		LogKeyComponent, LogComponentManagedService,
+	))
+	ms.metrics = cfg.Metrics
+	ms.tracer = newTracer(cfg.TracerProvider)
+
+	id := cfg.ID
+	baseChecks := cfg.BaseChecks
+	sidecar := cfg.SidecarService
+
+	if reg := cfg.Registration; reg != nil {
+		if reg.Name == "" {
+			return nil, errors.New("bootstrap registration must define a name")
+		}
+		if id == "" {
+			id = reg.ID
+		} else if reg.ID != "" && reg.ID != id {
+			return nil, fmt.Errorf("bootstrap registration and managed service config id mismatch: %q vs %q", reg.ID, id)
+		}
+		if id == "" {
+			id = ReplaceSlugs(ServiceDefaultIDFormat, SlugParams{Name: reg.Name, Addr: reg.Address})
+		}
+		if len(baseChecks) == 0 {
+			if reg.Check != nil {
+				baseChecks = append(baseChecks, reg.Check)
+			}
+			baseChecks = append(baseChecks, reg.Checks...)
+		}
+		if sidecar == nil && reg.Connect != nil {
+			sidecar = reg.Connect.SidecarService
+		}
+
+		ms.svc = agentServiceFromRegistration(id, reg)
+		ms.bootstrapping = true
+	}
+
+	if id == "" {
 		return nil, errors.New("id must be set in config")
 	}
 
-	// copy base checks to new slice.
-	if l := len(cfg.BaseChecks); l > 0 {
+	// store service id
+	ms.serviceID = id
+	ms.log = ms.log.With(LogKeyServiceID, id)
+
+	if cfg.DeregisterCriticalServiceAfter > 0 {
+		ms.deregisterAfter = cfg.DeregisterCriticalServiceAfter.String()
+	} else if cfg.DeregisterCriticalServiceAfter == 0 {
+		ms.deregisterAfter = ServiceDefaultDeregisterCriticalServiceAfter.String()
+	}
+
+	ms.sweepOrphans = cfg.SweepOrphans
+	if cfg.SweepOrphansConfirmWindow > 0 {
+		ms.sweepWindow = cfg.SweepOrphansConfirmWindow
+	} else {
+		ms.sweepWindow = ServiceDefaultSweepOrphansConfirmWindow
+	}
+
+	if cfg.BootstrapRetryInterval > 0 {
+		ms.bootstrapRetry = cfg.BootstrapRetryInterval
+	} else {
+		ms.bootstrapRetry = ServiceDefaultBootstrapRetryInterval
+	}
+
+	// copy base checks to new slice, setting the id the agent would have assigned to any check without one
+	if l := len(baseChecks); l > 0 {
 		ms.baseChecks = make(api.AgentServiceChecks, l, l)
-		copy(ms.baseChecks, cfg.BaseChecks)
+		for i, check := range baseChecks {
+			c := *check
+			if c.CheckID == "" {
+				c.CheckID = defaultServiceCheckID(ms.serviceID, i, l)
+			}
+			if c.DeregisterCriticalServiceAfter == "" {
+				c.DeregisterCriticalServiceAfter = ms.deregisterAfter
+			}
+			ms.baseChecks[i] = &c
+		}
 	} else {
 		ms.baseChecks = make(api.AgentServiceChecks, 0, 0)
 	}
 
-	// store service id
-	ms.serviceID = cfg.ID
-
 	// ensure we have a consul client
 	if cfg.Client != nil {
 		ms.client = cfg.Client
@@ -206,11 +717,85 @@ func NewManagedService(cfg *ManagedServiceConfig) (*ManagedService, error) {
 	ms.forceRefresh = make(chan chan error)
 	ms.stop = make(chan chan error)
 
-	// fetch initial service state from node
+	// drain settings
+	switch cfg.DrainMode {
+	case ManagedServiceDrainModeNone, ManagedServiceDrainModeMaintenance, ManagedServiceDrainModeCritical:
+		ms.drainMode = cfg.DrainMode
+	default:
+		return nil, fmt.Errorf("unknown drain mode %d (%[1]s)", cfg.DrainMode)
+	}
+	if cfg.DrainGracePeriod > 0 {
+		ms.drainGrace = cfg.DrainGracePeriod
+	} else {
+		ms.drainGrace = ServiceDefaultDrainGracePeriod
+	}
+	ms.drainWaitForHealth = cfg.DrainWaitForHealth
+	ms.watchCatalog = cfg.WatchCatalog
+
+	ms.desired = cfg.DesiredState.clone()
+
+	// healing rules
+	if l := len(cfg.HealingRules); l > 0 {
+		ms.healingRules = make([]ManagedServiceHealingRule, l)
+		for i, rule := range cfg.HealingRules {
+			if rule.After <= 0 {
+				return nil, fmt.Errorf("healing rule %d must define a positive After duration", i)
+			}
+			switch rule.Action {
+			case ManagedServiceHealingActionHook:
+				if rule.Hook == nil {
+					return nil, fmt.Errorf("healing rule %d has action %s but no Hook", i, rule.Action)
+				}
+			case ManagedServiceHealingActionReRegisterCheck:
+			case ManagedServiceHealingActionExit:
+				if rule.ExitCode == 0 {
+					rule.ExitCode = 1
+				}
+				if rule.Exit == nil {
+					rule.Exit = os.Exit
+				}
+				if rule.ExitNotificationTimeout <= 0 {
+					rule.ExitNotificationTimeout = ServiceDefaultHealingExitNotificationTimeout
+				}
+			default:
+				return nil, fmt.Errorf("healing rule %d has unknown action %d (%[2]s)", i, rule.Action)
+			}
+			ms.healingRules[i] = rule
+		}
+		ms.healingFired = make(map[managedServiceHealingKey]time.Time)
+	}
+
+	if sidecar != nil {
+		ms.sidecar = cloneServiceRegistration(sidecar)
+	}
+
+	// join the bus before the initial refresh so that its notifications are re-published, leaving again if
+	// construction fails
+	var busID string
+	if cfg.NotificationBus != nil {
+		busID = cfg.NotificationBus.join(id, ms)
+	}
+
+	// fetch initial service state from node.  if a bootstrap registration was provided, failure is not fatal as
+	// registration will be retried once running.
+	// a bootstrap registration that succeeds here will start the orphan sweep itself.
+	bootstrapping := ms.bootstrapping
 	ctx, cancel := context.WithTimeout(context.Background(), ms.rttl)
 	defer cancel()
 	if _, err = ms.refreshService(ctx); err != nil {
-		return nil, fmt.Errorf("error fetching current state of service: %s", err)
+		if !ms.bootstrapping {
+			leaveNotificationBus(cfg.NotificationBus, busID)
+			return nil, fmt.Errorf("error fetching current state of service: %s", err)
+		}
+		if ms.bootstrapErr != nil {
+			leaveNotificationBus(cfg.NotificationBus, busID)
+			return nil, fmt.Errorf("bootstrap registration rejected: %w", ms.bootstrapErr)
+		}
+		ms.log.Warn("Unable to register bootstrap definition, will retry", "retry_every", ms.bootstrapRetry, LogKeyError, err)
+	}
+
+	if ms.sweepOrphans && !bootstrapping {
+		go ms.runOrphanSweep()
 	}
 
 	return ms, ms.Register()
@@ -275,12 +860,12 @@ func (ms *ManagedService) Register() error {
 	defer ms.mu.Unlock()
 
 	if ms.state == ManagedServiceStateRunning {
-		ms.logf(true, "Register() called but we're already running")
+		ms.log.Debug("Register() called but we're already running")
 		return nil
 	}
 
 	if ms.state == ManagedServiceStateShutdowned {
-		ms.logf(false, "Register() called but we're shutdowned")
+		ms.log.Info("Register() called but we're shutdowned")
 		return errors.New("managed service is shutdowned")
 	}
 
@@ -291,19 +876,20 @@ func (ms *ManagedService) Register() error {
 	return nil
 }
 
-// Deregister stops internal maintenance routines and removes the managed service from the connected consul agent
+// Deregister stops internal maintenance routines and removes the managed service from the connected consul agent.  If a
+// DrainMode was configured, the service is first taken out of rotation and allowed to drain.
 func (ms *ManagedService) Deregister() error {
 	ms.mu.Lock()
 
 	if ms.state == ManagedServiceStateStopped {
 		ms.mu.Unlock()
-		ms.logf(true, "Deregister() called but we're already deregistered")
+		ms.log.Debug("Deregister() called but we're already deregistered")
 		return nil
 	}
 
 	if ms.state == ManagedServiceStateShutdowned {
 		ms.mu.Unlock()
-		ms.logf(false, "Deregister() called but we're shutdown")
+		ms.log.Info("Deregister() called but we're shutdown")
 		return errors.New("managed service is shutdowned")
 	}
 
@@ -314,13 +900,14 @@ func (ms *ManagedService) Deregister() error {
 	return ms.waitForStop()
 }
 
-// Shutdown stops internal maintenance routines and removes the managed service from the connected consul agent.  Once
-// shutdown, the managed service is considered defunct.
+// Shutdown stops internal maintenance routines and removes the managed service from the connected consul agent.  If a
+// DrainMode was configured, the service is first taken out of rotation and allowed to drain.  Once shutdown, the managed
+// service is considered defunct.
 func (ms *ManagedService) Shutdown() error {
 	ms.mu.Lock()
 	if ms.state == ManagedServiceStateShutdowned {
 		ms.mu.Unlock()
-		ms.logf(true, "Shutdown() called but we're already shutdown")
+		ms.log.Debug("Shutdown() called but we're already shutdown")
 		return nil
 	}
 
@@ -392,7 +979,7 @@ func (ms *ManagedService) Checks(ctx context.Context) (api.HealthChecks, *api.Qu
 }
 
 // AddTags attempts to add one or more tags to the service registration in consul, if and only if EnableTagOverride was
-// enabled when the service was registered.
+// enabled when the service was registered.  It is built on top of MutateTags, and is therefore safe to use concurrently.
 //
 // Returns:
 //	- count of tags added
@@ -404,44 +991,32 @@ func (ms *ManagedService) AddTags(tags ...string) (int, error) {
 		return 0, errors.New("managed service is not running")
 	}
 
-	ms.mu.RLock()
-
-	if !ms.svc.EnableTagOverride {
-		ms.mu.RUnlock()
-		return 0, errors.New("cannot add tags: EnableTagOverride was false at service registration")
-	}
-
-	ms.logf(true, "AddTags() - Adding tags %v to service %q...", tags, ms.serviceID)
+	ms.log.Debug("Adding tags", "tags", tags)
 	if len(tags) == 0 {
-		ms.logf(true, "AddTags() - Empty tag set provided")
-		ms.mu.RUnlock()
+		ms.log.Debug("Empty tag set provided")
 		return 0, nil
 	}
 
-	var (
-		newTags []string
-		added   int
-		err     error
-	)
-	if newTags, added = helpers.CombineStringSlices(ms.svc.Tags, tags); added == 0 {
-		ms.logf(true, "AddTags() - no new tags provided")
-	} else if err = ms.registerService(false, newTags); err != nil {
-		added = 0
-	}
-
-	ms.pushNotification(NotificationEventManagedServiceTagsAdded, ms.buildUpdate(err))
+	var added int
 
-	ms.mu.RUnlock()
+	_, _, err := ms.mutateTags(NotificationEventManagedServiceTagsAdded, func(current []string) []string {
+		var newTags []string
+		if newTags, added = helpers.CombineStringSlices(current, tags); added == 0 {
+			ms.log.Debug("No new tags provided")
+		}
+		return newTags
+	})
 
-	if err == nil {
-		err = ms.ForceRefresh()
+	if err != nil {
+		return 0, fmt.Errorf("cannot add tags: %w", err)
 	}
 
-	return added, err
+	return added, nil
 }
 
 // RemoveTags attempts to remove one or more tags from the service registration in consul, if and only if
-// EnableTagOverride was enabled when the service was registered.
+// EnableTagOverride was enabled when the service was registered.  It is built on top of MutateTags, and is therefore
+// safe to use concurrently.
 //
 // Returns:
 //	- count of tags removed
@@ -453,254 +1028,1303 @@ func (ms *ManagedService) RemoveTags(tags ...string) (int, error) {
 		return 0, errors.New("managed service is not running")
 	}
 
-	ms.mu.RLock()
+	ms.log.Debug("Removing tags", "tags", tags)
+	if len(tags) == 0 {
+		ms.log.Debug("Empty tag set provided")
+		return 0, nil
+	}
+
+	var removed int
+
+	_, _, err := ms.mutateTags(NotificationEventManagedServiceTagsRemoved, func(current []string) []string {
+		var newTags []string
+		if newTags, removed = helpers.RemoveStringsFromSlice(current, tags); removed == 0 {
+			ms.log.Debug("Service does not have any of the provided tags", "tags", tags)
+		}
+		return newTags
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("cannot remove tags: %w", err)
+	}
+
+	return removed, nil
+}
+
+// MutateTags provides compare-and-swap style modification of the service's tags, if and only if EnableTagOverride was
+// enabled when the service was registered.
+//
+// The current tags are fetched directly from the agent and provided to fn, the result of which is registered as the
+// new tag set and then verified.  Should the service be seen to be modified by something else before the new tag set is
+// written, the attempt is retried with the newly fetched tags up to ServiceMutateTagsAttempts times.  As such, fn may
+// be called more than once and must not have side effects that cannot be repeated.  fn may safely call other methods of
+// this managed service, with the exception of those that themselves mutate tags, such as AddTags or RemoveTags.
+//
+// This is best-effort: the agent API offers no compare-and-swap for service registrations, so a write made by
+// something else between the final conflict check and the registration may be silently replaced.  If the tags read
+// back after writing do not match the mutation, ErrServiceTagsOverwritten is returned and no further attempt is made.
+//
+// Returns the final set of tags registered with the service.
+func (ms *ManagedService) MutateTags(fn func(current []string) []string) ([]string, error) {
+	if fn == nil {
+		return nil, errors.New("fn cannot be nil")
+	}
+	if !ms.Running() {
+		return nil, errors.New("managed service is not running")
+	}
+	_, after, err := ms.mutateTags(NotificationEventManagedServiceTagsMutated, fn)
+	return after, err
+}
+
+// CheckDefinitions returns a copy of the most recent snapshot of check definitions registered to this service.  These
+// are the checks that will be registered should the service need to be re-registered.
+func (ms *ManagedService) CheckDefinitions() api.AgentServiceChecks {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	src := ms.checks
+	if src == nil {
+		src = ms.baseChecks
+	}
+	out := make(api.AgentServiceChecks, len(src))
+	for i, check := range src {
+		c := *check
+		out[i] = &c
+	}
+	return out
+}
+
+// SidecarServiceID returns the id of the Connect sidecar proxy registered alongside this service, if one was defined
+func (ms *ManagedService) SidecarServiceID() string {
+	ms.mu.RLock()
+	id := ms.sidecarServiceID()
+	ms.mu.RUnlock()
+	return id
+}
+
+// Health returns the aggregate status of all checks registered to this service as of the last refresh: one of
+// api.HealthPassing, api.HealthWarning, or api.HealthCritical.  A service in maintenance mode is considered critical.
+func (ms *ManagedService) Health() string {
+	ms.mu.RLock()
+	h := ms.health
+	ms.mu.RUnlock()
+	return h
+}
+
+// ManagedServiceOrphanError describes a failure to confirm or deregister a single possible orphan during SweepOrphans
+type ManagedServiceOrphanError struct {
+	ServiceID string
+	Err       error
+}
+
+func (e *ManagedServiceOrphanError) Error() string {
+	return fmt.Sprintf("orphan %q: %s", e.ServiceID, e.Err)
+}
+
+func (e *ManagedServiceOrphanError) Unwrap() error {
+	return e.Err
+}
+
+// SweepOrphans finds earlier registrations of this service on the local agent that were left behind by processes that
+// died without deregistering, and deregisters them.  A service is considered orphaned if it has the same name and
+// address as this one, has an id matching ServiceDefaultIDFormat, and every one of its checks has run and is critical.
+// Services without checks are never considered orphaned.
+//
+// As the checks of a freshly registered service start out critical, candidates are only deregistered if they are seen
+// to still be critical once a confirmation window has passed.  The window is the greater of SweepOrphansConfirmWindow
+// and the longest interval of the candidates' checks, and this call blocks for its duration if any candidates are
+// found.  ctx must allow for this.
+//
+// The ids of all orphans successfully deregistered are returned, along with any errors seen.  Each error relating to a
+// specific service is a *ManagedServiceOrphanError, joined with the others.
+func (ms *ManagedService) SweepOrphans(ctx context.Context) ([]string, error) {
+	var (
+		svcs       map[string]*api.AgentService
+		candidates []string
+		swept      []string
+		errs       []error
+		err        error
+		pattern    *regexp.Regexp
+		name, id   string
+		window     time.Duration
+	)
+
+	ms.mu.RLock()
+	name = ms.svc.Service
+	id = ms.serviceID
+	window = ms.sweepWindow
+	pattern, err = SlugPattern(ServiceDefaultIDFormat, SlugParams{Name: name, Addr: ms.svc.Address})
+	ms.mu.RUnlock()
+
+	if err != nil {
+		return nil, fmt.Errorf("error compiling orphan id pattern: %w", err)
+	}
+
+	if svcs, err = ms.client.Agent().ServicesWithFilterOpts(fmt.Sprintf("Service == %q", name), ms.qo.WithContext(ctx)); err != nil {
+		return nil, fmt.Errorf("error fetching services: %w", err)
+	}
+
+	for sid := range svcs {
+		if sid == id || !pattern.MatchString(sid) {
+			continue
+		}
+		orphaned, interval, err := ms.orphanCandidate(ctx, sid)
+		if err != nil {
+			errs = append(errs, &ManagedServiceOrphanError{ServiceID: sid, Err: err})
+			continue
+		}
+		if !orphaned {
+			continue
+		}
+		if interval > window {
+			window = interval
+		}
+		candidates = append(candidates, sid)
+	}
+
+	if len(candidates) == 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	sort.Strings(candidates)
+
+	ms.log.Debug("Possible orphans found, waiting to confirm", "orphan_ids", candidates, "window", window)
+
+	timer := time.NewTimer(window)
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		timer.Stop()
+		return nil, errors.Join(append(errs, fmt.Errorf("context finished before orphans were confirmed: %w", ctx.Err()))...)
+	}
+
+	for _, sid := range candidates {
+		orphaned, _, err := ms.orphanCandidate(ctx, sid)
+		if err != nil {
+			errs = append(errs, &ManagedServiceOrphanError{ServiceID: sid, Err: err})
+			continue
+		}
+		if !orphaned {
+			ms.log.Debug("Service no longer appears to be orphaned", "orphan_id", sid)
+			continue
+		}
+
+		ms.log.Info("Service appears to be orphaned, deregistering", "orphan_id", sid)
+
+		sctx, span := startSpan(ctx, ms.tracer, TraceSpanAgentServiceDeregister, TraceAttributeServiceID.String(sid))
+		err = ms.client.Agent().ServiceDeregisterOpts(sid, ms.qo.WithContext(sctx))
+		endSpan(span, err)
+		if err != nil && !IsNotFoundError(err) {
+			ms.log.Error("Error deregistering orphaned service", "orphan_id", sid, LogKeyError, err)
+			errs = append(errs, &ManagedServiceOrphanError{ServiceID: sid, Err: fmt.Errorf("error deregistering service: %w", err)})
+			continue
+		}
+
+		swept = append(swept, sid)
+
+		ms.mu.RLock()
+		up := ms.buildUpdate(nil)
+		ms.mu.RUnlock()
+		up.OrphanID = sid
+		ms.pushNotification(NotificationEventManagedServiceOrphanSwept, up)
+	}
+
+	return swept, errors.Join(errs...)
+}
+
+// orphanCandidate fetches the checks of the service with the provided id, returning true if it has at least one check
+// and every one has run and is critical, along with the longest interval of its checks.  A service that has been
+// deregistered in the meantime is not considered a candidate.
+func (ms *ManagedService) orphanCandidate(ctx context.Context, sid string) (bool, time.Duration, error) {
+	var interval time.Duration
+
+	ctx, span := startSpan(ctx, ms.tracer, TraceSpanAgentChecks, TraceAttributeServiceID.String(sid))
+	checks, err := ms.client.Agent().ChecksWithFilterOpts(fmt.Sprintf("ServiceID == %q", sid), ms.qo.WithContext(ctx))
+	endSpan(span, err)
+	if err != nil {
+		return false, 0, fmt.Errorf("error fetching checks: %w", err)
+	}
+	if len(checks) == 0 {
+		return false, 0, nil
+	}
+
+	for _, check := range checks {
+		// a check without output has yet to run
+		if check.Status != api.HealthCritical || check.Output == "" {
+			return false, 0, nil
+		}
+		if check.Definition.IntervalDuration > interval {
+			interval = check.Definition.IntervalDuration
+		}
+	}
+
+	return true, interval, nil
+}
+
+// runOrphanSweep calls SweepOrphans, logging any error seen.  It is intended to be run in its own goroutine.
+func (ms *ManagedService) runOrphanSweep() {
+	ms.mu.RLock()
+	timeout := 2*ms.sweepWindow + 4*ms.rttl
+	ms.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if _, err := ms.SweepOrphans(ctx); err != nil {
+		ms.log.Error("Error sweeping orphaned services", LogKeyError, err)
+	}
+}
+
+// Bootstrapping returns true if this managed service was constructed with a bootstrap registration that has yet to be
+// seen on the agent
+func (ms *ManagedService) Bootstrapping() bool {
+	ms.mu.RLock()
+	b := ms.bootstrapping
+	ms.mu.RUnlock()
+	return b
+}
+
+// InMaintenance returns true if this managed service has been placed into maintenance mode, either via
+// EnableMaintenance or externally as of the last refresh
+func (ms *ManagedService) InMaintenance() bool {
+	ms.mu.RLock()
+	m := ms.maint
+	ms.mu.RUnlock()
+	return m
+}
+
+// EnableMaintenance attempts to place the service into maintenance mode with the provided reason, removing it from
+// healthy query results until DisableMaintenance is called.  The maintenance state is retained by this managed service
+// and will be re-applied should the service need to be re-registered.
+func (ms *ManagedService) EnableMaintenance(ctx context.Context, reason string) error {
+	if !ms.Running() {
+		return errors.New("managed service is not running")
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.log.Debug("Enabling maintenance mode", "reason", reason)
+
+	ctx, span := startSpan(ctx, ms.tracer, TraceSpanAgentMaintenanceEnable, TraceAttributeServiceID.String(ms.serviceID))
+	err := ms.client.Agent().EnableServiceMaintenanceOpts(ms.serviceID, reason, ms.qo.WithContext(ctx))
+	endSpan(span, err)
+	if err != nil {
+		ms.log.Error("Error enabling maintenance mode", LogKeyError, err)
+	} else {
+		ms.maint = true
+		ms.maintReason = reason
+	}
+
+	ms.pushNotification(NotificationEventManagedServiceMaintenanceOn, ms.buildUpdate(err))
+
+	return err
+}
+
+// DisableMaintenance attempts to take the service out of maintenance mode.
+func (ms *ManagedService) DisableMaintenance(ctx context.Context) error {
+	if !ms.Running() {
+		return errors.New("managed service is not running")
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.log.Debug("Disabling maintenance mode")
+
+	ctx, span := startSpan(ctx, ms.tracer, TraceSpanAgentMaintenanceDisable, TraceAttributeServiceID.String(ms.serviceID))
+	err := ms.client.Agent().DisableServiceMaintenanceOpts(ms.serviceID, ms.qo.WithContext(ctx))
+	endSpan(span, err)
+	if err != nil {
+		ms.log.Error("Error disabling maintenance mode", LogKeyError, err)
+	} else {
+		ms.maint = false
+		ms.maintReason = ""
+	}
+
+	ms.pushNotification(NotificationEventManagedServiceMaintenanceOff, ms.buildUpdate(err))
+
+	return err
+}
+
+// DesiredState returns a copy of the currently enforced desired state, if one is defined
+func (ms *ManagedService) DesiredState() *ManagedServiceDesiredState {
+	ms.mu.RLock()
+	ds := ms.desired.clone()
+	ms.mu.RUnlock()
+	return ds
+}
+
+// SetDesiredState replaces the desired state enforced by this managed service, immediately reconciling the upstream
+// registration.  Providing nil will cease enforcement.
+func (ms *ManagedService) SetDesiredState(ds *ManagedServiceDesiredState) error {
+	return ms.updateDesiredState(func(cur *ManagedServiceDesiredState) *ManagedServiceDesiredState {
+		return ds.clone()
+	})
+}
+
+// SetMeta sets the exact set of meta values the service must be registered with, immediately reconciling the upstream
+// registration.
+func (ms *ManagedService) SetMeta(meta map[string]string) error {
+	if meta == nil {
+		meta = make(map[string]string)
+	}
+	return ms.updateDesiredState(func(cur *ManagedServiceDesiredState) *ManagedServiceDesiredState {
+		cur.Meta = make(map[string]string, len(meta))
+		for k, v := range meta {
+			cur.Meta[k] = v
+		}
+		return cur
+	})
+}
+
+// SetWeights sets the weights the service must be registered with, immediately reconciling the upstream registration.
+func (ms *ManagedService) SetWeights(passing, warning int) error {
+	return ms.updateDesiredState(func(cur *ManagedServiceDesiredState) *ManagedServiceDesiredState {
+		cur.Weights = &api.AgentWeights{Passing: passing, Warning: warning}
+		return cur
+	})
+}
+
+// updateDesiredState modifies the desired state using the provided func, and forces a refresh so that it is enforced.
+// the func is always provided a non-nil copy of the current desired state.
+func (ms *ManagedService) updateDesiredState(fn func(*ManagedServiceDesiredState) *ManagedServiceDesiredState) error {
+	if !ms.Running() {
+		return errors.New("managed service is not running")
+	}
+
+	ms.mu.Lock()
+	cur := ms.desired.clone()
+	if cur == nil {
+		cur = new(ManagedServiceDesiredState)
+	}
+	ms.desired = fn(cur)
+	ms.mu.Unlock()
+
+	return ms.ForceRefresh()
+}
+
+// ForceRefresh attempts an immediate internal state refresh, blocking until attempt has been completed.
+func (ms *ManagedService) ForceRefresh() error {
+	if !ms.Running() {
+		return errors.New("managed service is not running")
+	}
+	ms.log.Debug("Force refresh requested")
+	ch := make(chan error, 1)
+	defer close(ch)
+	ms.forceRefresh <- ch
+	return <-ch
+}
+
+// buildUpdate constructs a notification update type
+//
+// caller must hold lock
+func (ms *ManagedService) buildUpdate(err error) ManagedServiceUpdate {
+	return ManagedServiceUpdate{
+		ServiceID:     ms.svc.ID,
+		ServiceName:   ms.svc.Service,
+		LastRefreshed: ms.localRefreshed,
+		Maintenance:   ms.maint,
+		MaintReason:   ms.maintReason,
+		Health:        ms.health,
+		Error:         err,
+	}
+}
+
+// pushNotification constructs and then pushes a new notification to currently registered recipients based on the
+// current state of the service.
+func (ms *ManagedService) pushNotification(ev NotificationEvent, up ManagedServiceUpdate) {
+	ms.sendNotification(NotificationSourceManagedService, ev, up)
+}
+
+// setState updates the internal state value and pushes a notification of change
+//
+// caller must hold full lock
+func (ms *ManagedService) setState(state ManagedServiceState) {
+	var ev NotificationEvent
+
+	if ms.state == state {
+		return
+	}
+
+	switch state {
+	case ManagedServiceStateRunning:
+		ev = NotificationEventManagedServiceRunning
+	case ManagedServiceStateStopped:
+		ev = NotificationEventManagedServiceStopped
+	case ManagedServiceStateShutdowned:
+		ev = NotificationEventManagedServiceShutdowned
+
+	default:
+		panic(fmt.Sprintf("unkonwn state %d (%[1]s) seen", state))
+	}
+
+	ms.state = state
+
+	ms.pushNotification(ev, ms.buildUpdate(nil))
+}
+
+func (ms *ManagedService) waitForStop() error {
+	drop := make(chan error, 1)
+	ms.stop <- drop
+	err := <-drop
+	close(drop)
+	return err
+}
+
+func (ms *ManagedService) findAgentService(ctx context.Context) (*api.AgentService, *api.QueryMeta, error) {
+	return ms.client.Agent().Service(ms.serviceID, ms.qo.WithContext(ctx))
+}
+
+// findHealth must be used behind a lock
+func (ms *ManagedService) findHealth(ctx context.Context) (*api.ServiceEntry, *api.QueryMeta, error) {
+	var (
+		svcs []*api.ServiceEntry
+		qm   *api.QueryMeta
+		err  error
+	)
+	if svcs, qm, err = ms.client.Health().ServiceMultipleTags(ms.svc.Service, nil, false, ms.qo.WithContext(ctx)); err != nil {
+		return nil, qm, fmt.Errorf("error fetching service health: %s", err)
+	}
+	if svc, ok := SpecificServiceEntry(ms.serviceID, svcs); ok {
+		return svc, qm, nil
+	}
+	return nil, qm, fmt.Errorf("no service with name %q with id %q found", ms.svc.Service, ms.svc.ID)
+}
+
+// findCatalog must be used behind a lock
+func (ms *ManagedService) findCatalog(ctx context.Context) (*api.CatalogService, *api.QueryMeta, error) {
+	var (
+		svcs []*api.CatalogService
+		qm   *api.QueryMeta
+		err  error
+	)
+	if svcs, qm, err = ms.client.Catalog().ServiceMultipleTags(ms.svc.Service, nil, ms.qo.WithContext(ctx)); err != nil {
+		return nil, nil, fmt.Errorf("error fetching service from catalog: %s", err)
+	}
+	if svc, ok := SpecificCatalogService(ms.serviceID, svcs); ok {
+		return svc, qm, nil
+	}
+	return nil, qm, fmt.Errorf("no service with name %q with id %q found", ms.svc.Service, ms.serviceID)
+}
+
+// fetchChecks must be used behind a lock
+func (ms *ManagedService) fetchChecks(ctx context.Context) (api.HealthChecks, *api.QueryMeta, error) {
+	var (
+		checks api.HealthChecks
+		qm     *api.QueryMeta
+		err    error
+	)
+	ctx, span := startSpan(ctx, ms.tracer, TraceSpanHealthChecks, TraceAttributeServiceID.String(ms.serviceID))
+	checks, qm, err = ms.client.Health().Checks(ms.svc.Service, ms.qo.WithContext(ctx))
+	endSpan(span, err)
+	if err != nil {
+		return nil, qm, nil
+	}
+	return SpecificChecks(ms.serviceID, checks), qm, nil
+}
+
+// refreshService will refresh the internal representation of the service.  If fetch fails it will attempt to
+// re-register the service based on the last-known definition, returning an error if that itself fails.
+//
+// caller must hold full lock
+func (ms *ManagedService) refreshService(ctx context.Context) (*api.QueryMeta, error) {
+	var (
+		svc *api.AgentService
+		qm  *api.QueryMeta
+		err error
+	)
+
+	ms.log.Debug("Refreshing local service")
+
+	if svc, qm, err = ms.findAgentService(ctx); err != nil {
+		if ms.bootstrapping {
+			// a 404 is considered as the service not yet existing, and transport errors and 5xx responses as the agent
+			// not yet being available.  anything else is a permanent rejection that will not be retried.
+			if ms.bootstrapErr != nil {
+				err = ms.bootstrapErr
+			} else if !IsNotFoundError(err) && !isRetryableError(err) {
+				ms.bootstrapFailed(err)
+			} else {
+				ms.log.Info("Bootstrap registration not yet seen, attempting to register", LogKeyError, err)
+				if err = ms.registerService(ctx, true, ms.svc.Tags); err != nil {
+					ms.log.Error("Failed to register bootstrap definition", LogKeyError, err)
+					if !isRetryableError(err) {
+						ms.bootstrapFailed(err)
+					}
+				} else if svc, qm, err = ms.findAgentService(ctx); err != nil {
+					ms.log.Error("Failed to locate registered bootstrap definition", LogKeyError, err)
+				}
+			}
+
+		} else if IsNotFoundError(err) && ms.svc != nil {
+			ms.log.Warn("Service not found, attempting to re-register", LogKeyEvent, NotificationEventManagedServiceMissing.String())
+
+			ms.pushNotification(NotificationEventManagedServiceMissing, ms.buildUpdate(err))
+
+			if err = ms.registerService(ctx, true, ms.svc.Tags); err != nil {
+				ms.log.Error("Failed to re-register service", LogKeyError, err)
+			} else if svc, qm, err = ms.findAgentService(ctx); err != nil {
+				ms.log.Error("Failed to locate re-registered service", LogKeyError, err)
+			} else {
+				ms.log.Info("Service successfully re-registered")
+				ms.restoreMaintenance(ctx)
+			}
+
+		} else {
+			ms.log.Error("Error fetching service from node", LogKeyError, err)
+		}
+	}
+
+	if svc == nil && err == nil {
+		err = errors.New("unable to locate service after re-register")
+	}
+
+	if err == nil {
+		ms.svc = svc
+		ms.localRefreshed = time.Now()
+
+		if ms.bootstrapping {
+			ms.bootstrapping = false
+			ms.log.Info("Bootstrap registration complete", LogKeyEvent, NotificationEventManagedServiceBootstrapped.String())
+			ms.pushNotification(NotificationEventManagedServiceBootstrapped, ms.buildUpdate(nil))
+			if ms.sweepOrphans {
+				go ms.runOrphanSweep()
+			}
+		}
+
+		ms.log.Debug("Service refreshed", "tags", svc.Tags, "port", svc.Port, "address", svc.Address)
+
+		// failure to snapshot checks is not considered a refresh failure, the previous snapshot is retained.
+		if cerr := ms.snapshotChecks(ctx); cerr != nil {
+			ms.log.Error("Error snapshotting service checks", LogKeyError, cerr)
+		}
+
+		// ensure sidecar is still with us
+		if ms.sidecar != nil {
+			err = ms.ensureSidecar(ctx)
+		}
+
+		// enforce desired state
+		if err == nil && ms.desired != nil {
+			if drift := ms.desired.drift(ms.svc); len(drift) > 0 {
+				err = ms.reconcile(ctx, drift)
+			}
+		}
+	}
+
+	if ms.metrics != nil {
+		ms.metrics.ServiceRefreshed(ms.serviceID, err)
+	}
+
+	ms.pushNotification(NotificationEventManagedServiceRefreshed, ms.buildUpdate(err))
+
+	return qm, err
+}
+
+// mutateTags performs the tag mutation retry loop, pushing a notification with the provided event once complete.
+func (ms *ManagedService) mutateTags(ev NotificationEvent, fn func([]string) []string) ([]string, []string, error) {
+	var (
+		before, after []string
+		err           error
+	)
+
+	ms.mu.RLock()
+	override := ms.svc.EnableTagOverride
+	ms.mu.RUnlock()
+
+	if !override {
+		return nil, nil, errors.New("EnableTagOverride was false at service registration")
+	}
+
+	ms.tmu.Lock()
+	defer ms.tmu.Unlock()
+
+	for i := 1; i <= ServiceMutateTagsAttempts; i++ {
+		if before, after, err = ms.tryMutateTags(fn); !errors.Is(err, ErrServiceTagsConflict) {
+			break
+		}
+		ms.log.Warn("Conflict seen while mutating tags", "attempt", i, "max_attempts", ServiceMutateTagsAttempts)
+	}
+
+	ms.mu.RLock()
+	up := ms.buildUpdate(err)
+	up.PreviousTags = before
+	up.Tags = after
+	ms.pushNotification(ev, up)
+	ms.mu.RUnlock()
+
+	return before, after, err
+}
+
+// tryMutateTags makes a single tag mutation attempt, returning ErrServiceTagsConflict if the service was seen to be
+// modified by something else between fetching the tags provided to fn and writing its result, or
+// ErrServiceTagsOverwritten if it was seen to be modified after.
+//
+// fn is called without holding any lock, so it may safely call any other method of this managed service.
+func (ms *ManagedService) tryMutateTags(fn func([]string) []string) ([]string, []string, error) {
+	var (
+		cur, chk *api.AgentService
+		before   []string
+		after    []string
+		err      error
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), ms.rttl)
+	defer cancel()
+
+	// fetch current upstream state
+	if cur, _, err = ms.findAgentService(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	before = make([]string, len(cur.Tags))
+	copy(before, cur.Tags)
+	in := make([]string, len(cur.Tags))
+	copy(in, cur.Tags)
+
+	if after = fn(in); strSlicesEqual(before, after) {
+		ms.log.Debug("Tags unchanged, nothing to do")
+		ms.mu.Lock()
+		ms.svc = cur
+		ms.mu.Unlock()
+		return before, before, nil
+	}
+
+	// hold the lock from the final check through to verification, so that nothing else within this managed service
+	// may write to the agent in between
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	// ensure the service was not modified since the tags provided to fn were fetched
+	if chk, _, err = ms.findAgentService(ctx); err != nil {
+		return before, nil, err
+	} else if chk.ContentHash != cur.ContentHash {
+		ms.svc = chk
+		return before, nil, ErrServiceTagsConflict
+	}
+
+	// registration is built from the current state, which is known to match what fn was provided
+	ms.svc = chk
+
+	if err = ms.registerService(ctx, false, after); err != nil {
+		return before, nil, err
+	}
+
+	// verify result
+	if chk, _, err = ms.findAgentService(ctx); err != nil {
+		return before, nil, err
+	}
+
+	ms.svc = chk
+	ms.localRefreshed = time.Now()
+
+	if !strSlicesEqual(chk.Tags, after) {
+		return before, chk.Tags, ErrServiceTagsOverwritten
+	}
+
+	return before, after, nil
+}
+
+// reconcile re-registers the service to correct the provided drift, pushing a notification describing what was
+// corrected.
+//
+// caller must hold full lock
+func (ms *ManagedService) reconcile(ctx context.Context, drift []ManagedServiceFieldDrift) error {
+	var (
+		svc *api.AgentService
+		err error
+	)
+
+	ms.log.Info("Service has drifted from desired state, correcting", "drift", drift)
+
+	if err = ms.registerService(ctx, false, ms.svc.Tags); err != nil {
+		ms.log.Error("Failed to re-register service", LogKeyError, err)
+	} else if svc, _, err = ms.findAgentService(ctx); err != nil {
+		ms.log.Error("Failed to locate re-registered service", LogKeyError, err)
+	} else {
+		ms.svc = svc
+		ms.localRefreshed = time.Now()
+	}
+
+	up := ms.buildUpdate(err)
+	up.Drift = drift
+	ms.pushNotification(NotificationEventManagedServiceDrift, up)
+
+	return err
+}
+
+// knownCheck returns the locally known definition of a check, looking first at the previous snapshot and then the base
+// checks
+//
+// caller must hold lock
+func (ms *ManagedService) knownCheck(checkID string) *api.AgentServiceCheck {
+	for _, checks := range []api.AgentServiceChecks{ms.checks, ms.baseChecks} {
+		for _, check := range checks {
+			if check.CheckID == checkID {
+				return check
+			}
+		}
+	}
+	return nil
+}
+
+// snapshotChecks fetches the current definitions of all checks registered to this service from the agent, merging them
+// with any locally known definitions to fill in attributes the agent does not expose.
+//
+// caller must hold full lock
+func (ms *ManagedService) snapshotChecks(ctx context.Context) error {
+	var (
+		checks map[string]*api.AgentCheck
+		ids    []string
+		err    error
+	)
+
+	ctx, span := startSpan(ctx, ms.tracer, TraceSpanAgentChecks, TraceAttributeServiceID.String(ms.serviceID))
+	checks, err = ms.client.Agent().ChecksWithFilterOpts(fmt.Sprintf("ServiceID == %q", ms.serviceID), ms.qo.WithContext(ctx))
+	endSpan(span, err)
+	if err != nil {
+		return err
+	}
+
+	ms.updateHealth(checks)
+	ms.updateMaintenance(checks)
+
+	ids = make([]string, 0, len(checks))
+	for id := range checks {
+		// maintenance and drain checks are managed separately
+		if isManagedServiceInternalCheck(id) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	defs := make(api.AgentServiceChecks, 0, len(ids))
+	for _, id := range ids {
+		if def := agentCheckDefinition(checks[id], ms.knownCheck(id)); def != nil {
+			defs = append(defs, def)
+		} else {
+			ms.log.Warn("Check cannot be rebuilt from agent definition and has no known base, it will not be re-registered", "check_id", id, "check_type", checks[id].Type)
+		}
+	}
+
+	ms.checks = defs
+
+	ms.log.Debug("Snapshotted checks", "count", len(defs))
+
+	return nil
+}
+
+// updateMaintenance derives the maintenance state of the service from the agent's maintenance check, pushing a
+// notification if it was changed outside of this managed service
+//
+// caller must hold full lock
+func (ms *ManagedService) updateMaintenance(checks map[string]*api.AgentCheck) {
+	var (
+		maint  bool
+		reason string
+	)
+
+	if check, ok := checks[api.ServiceMaintPrefix+ms.serviceID]; ok {
+		maint = true
+		reason = check.Notes
+	}
+
+	if maint == ms.maint {
+		if maint {
+			ms.maintReason = reason
+		}
+		return
+	}
+
+	ms.maint = maint
+	ms.maintReason = reason
+
+	if maint {
+		ms.log.Info("Service was placed into maintenance mode externally", "reason", reason)
+		ms.pushNotification(NotificationEventManagedServiceMaintenanceOn, ms.buildUpdate(nil))
+	} else {
+		ms.log.Info("Service was taken out of maintenance mode externally")
+		ms.pushNotification(NotificationEventManagedServiceMaintenanceOff, ms.buildUpdate(nil))
+	}
+}
+
+// updateHealth records the status of each of the provided checks, pushing a notification if the aggregate health of the
+// service has transitioned.  Maintenance and drain checks are recorded but do not count toward aggregate health, as
+// they reflect a deliberate decision to take the service out of rotation rather than a failure.
+//
+// caller must hold full lock
+func (ms *ManagedService) updateHealth(checks map[string]*api.AgentCheck) {
+	var (
+		changes []ManagedServiceCheckChange
+
+		prev   = ms.health
+		next   = api.HealthPassing
+		status = make(map[string]managedServiceCheckStatus, len(checks))
+	)
+
+	for id, check := range checks {
+		last, ok := ms.checkStatus[id]
+		cs := managedServiceCheckStatus{name: check.Name, status: check.Status, output: check.Output}
+		if check.Status == api.HealthCritical {
+			if ok && !last.criticalSince.IsZero() {
+				cs.criticalSince = last.criticalSince
+			} else {
+				cs.criticalSince = time.Now()
+			}
+		}
+		status[id] = cs
+		if isManagedServiceInternalCheck(id) {
+			continue
+		}
+		next = worseHealth(next, check.Status)
+		if !ok || last.status != check.Status {
+			changes = append(changes, ManagedServiceCheckChange{
+				CheckID:        id,
+				Name:           check.Name,
+				PreviousStatus: last.status,
+				Status:         check.Status,
+				Output:         check.Output,
+			})
+		}
+	}
+	for id, last := range ms.checkStatus {
+		if _, ok := status[id]; !ok && !isManagedServiceInternalCheck(id) {
+			changes = append(changes, ManagedServiceCheckChange{
+				CheckID:        id,
+				Name:           last.name,
+				PreviousStatus: last.status,
+			})
+		}
+	}
+
+	ms.checkStatus = status
+	ms.health = next
+
+	// the initial observation is not considered a transition
+	if prev == "" || prev == next {
+		return
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].CheckID < changes[j].CheckID })
+
+	ms.log.Info("Service health transitioned", "from", prev, "to", next, LogKeyEvent, NotificationEventManagedServiceHealthChanged.String())
+
+	up := ms.buildUpdate(nil)
+	up.PreviousHealth = prev
+	up.HealthChanges = changes
+	ms.pushNotification(NotificationEventManagedServiceHealthChanged, up)
+}
+
+// isManagedServiceInternalCheck returns true if id is that of a maintenance or drain check
+func isManagedServiceInternalCheck(id string) bool {
+	return strings.HasPrefix(id, api.ServiceMaintPrefix) || strings.HasPrefix(id, ServiceDrainCheckIDPrefix)
+}
+
+// managedServiceHealingKey identifies a single healing rule as applied to a single check
+type managedServiceHealingKey struct {
+	rule    int
+	checkID string
+}
+
+// evaluateHealingRules determines which healing rules are due to fire based on the current status of each check,
+// starting each due action in its own goroutine
+//
+// caller must hold full lock
+func (ms *ManagedService) evaluateHealingRules() {
+	if len(ms.healingRules) == 0 {
+		return
+	}
+
+	now := time.Now()
+
+	for key := range ms.healingFired {
+		// forget rules fired against checks that are no longer critical
+		if cs, ok := ms.checkStatus[key.checkID]; !ok || cs.criticalSince.IsZero() {
+			delete(ms.healingFired, key)
+		}
+	}
+
+	for i, rule := range ms.healingRules {
+		for id, cs := range ms.checkStatus {
+			if cs.criticalSince.IsZero() {
+				continue
+			}
+			if rule.CheckID != "" && rule.CheckID != id {
+				continue
+			}
+			if rule.CheckID == "" && isManagedServiceInternalCheck(id) {
+				continue
+			}
+			if now.Sub(cs.criticalSince) < rule.After {
+				continue
+			}
+
+			key := managedServiceHealingKey{rule: i, checkID: id}
+			if last, ok := ms.healingFired[key]; ok && (rule.Repeat <= 0 || now.Sub(last) < rule.Repeat) {
+				continue
+			}
+			ms.healingFired[key] = now
+
+			ms.log.Warn("Check has been critical beyond healing threshold, taking action", "check_id", id, "critical_since", cs.criticalSince, "action", rule.Action.String())
+
+			go ms.runHealingAction(rule, &ManagedServiceHealing{Action: rule.Action, CheckID: id, CriticalSince: cs.criticalSince})
+		}
+	}
+}
+
+// runHealingAction performs a single healing action, pushing a notification once complete
+func (ms *ManagedService) runHealingAction(rule ManagedServiceHealingRule, healing *ManagedServiceHealing) {
+	var err error
+
+	switch rule.Action {
+	case ManagedServiceHealingActionHook:
+		err = rule.Hook(context.Background(), ms, healing.CheckID)
+
+	case ManagedServiceHealingActionReRegisterCheck:
+		err = ms.reRegisterCheck(healing.CheckID)
+
+	case ManagedServiceHealingActionExit:
+		ms.mu.RLock()
+		up := ms.buildUpdate(nil)
+		ms.mu.RUnlock()
+		up.Healing = healing
+		ms.log.Error("Healing action exiting process", "exit_code", rule.ExitCode)
+		// the process is about to exit, so the notification must be handled before returning rather than queued
+		ms.sendNotificationWait(rule.ExitNotificationTimeout, NotificationSourceManagedService, NotificationEventManagedServiceHealingAction, up)
+		rule.Exit(rule.ExitCode)
+		return
+	}
+
+	if err != nil {
+		ms.log.Error("Healing action failed", "action", rule.Action.String(), "check_id", healing.CheckID, LogKeyError, err)
+	}
+
+	ms.mu.RLock()
+	up := ms.buildUpdate(err)
+	ms.mu.RUnlock()
+	up.Healing = healing
+	ms.pushNotification(NotificationEventManagedServiceHealingAction, up)
+}
+
+// reRegisterCheck re-registers the check with the provided id using its snapshotted definition, resetting its status
+func (ms *ManagedService) reRegisterCheck(checkID string) error {
+	reg := new(api.AgentCheckRegistration)
+
+	ms.mu.RLock()
+	def := ms.knownCheck(checkID)
+	if def != nil {
+		reg.AgentServiceCheck = *def
+		// the snapshotted status is the current one, reset to the initial status of the base definition, if any
+		reg.Status = ""
+		for _, base := range ms.baseChecks {
+			if base.CheckID == checkID {
+				reg.Status = base.Status
+			}
+		}
+	}
+	ms.mu.RUnlock()
+
+	if def == nil {
+		return fmt.Errorf("no definition known for check %q", checkID)
+	}
+
+	reg.ID = checkID
+	reg.Name = reg.AgentServiceCheck.Name
+	reg.ServiceID = ms.serviceID
+	reg.AgentServiceCheck.CheckID = ""
+	reg.AgentServiceCheck.Name = ""
+
+	ctx, cancel := context.WithTimeout(context.Background(), ms.rttl)
+	defer cancel()
+
+	return ms.client.Agent().CheckRegisterOpts(reg, ms.qo.WithContext(ctx))
+}
+
+// restoreMaintenance will attempt to put a re-registered service back into maintenance mode, if it was previously
+// placed there.
+//
+// caller must hold full lock
+func (ms *ManagedService) restoreMaintenance(ctx context.Context) {
+	if !ms.maint {
+		return
+	}
+
+	ms.log.Info("Service was in maintenance mode, re-enabling", "reason", ms.maintReason)
 
-	if !ms.svc.EnableTagOverride {
-		ms.mu.RUnlock()
-		return 0, errors.New("cannot remove tags: EnableTagOverride was false at service registration")
+	ctx, span := startSpan(ctx, ms.tracer, TraceSpanAgentMaintenanceEnable, TraceAttributeServiceID.String(ms.serviceID))
+	err := ms.client.Agent().EnableServiceMaintenanceOpts(ms.serviceID, ms.maintReason, ms.qo.WithContext(ctx))
+	endSpan(span, err)
+	if err != nil {
+		ms.log.Error("Error re-enabling maintenance mode", LogKeyError, err)
 	}
-	ms.logf(true, "RemoveTags() - Removing tags %v from service %q...", tags, ms.serviceID)
 
-	if len(tags) == 0 {
-		ms.logf(true, "RemoveTags() - Empty tag set provided")
-		ms.mu.RUnlock()
-		return 0, nil
-	}
+	ms.pushNotification(NotificationEventManagedServiceMaintenanceOn, ms.buildUpdate(err))
+}
 
-	var (
-		newTags []string
-		removed int
-		err     error
-	)
+// registerService will attempt to re-push the service to the consul agent
+//
+// caller must hold lock
+func (ms *ManagedService) registerService(ctx context.Context, missing bool, tags []string) error {
+	var err error
+	ms.log.Info("Registering service with node")
 
-	if newTags, removed = helpers.RemoveStringsFromSlice(ms.svc.Tags, tags); removed == 0 {
-		ms.logf(true, "RemoveTags() - Service does not have any tags from input %v", tags)
-	} else if err = ms.registerService(false, newTags); err != nil {
-		removed = 0
+	// registration always carries the full service definition, as anything omitted is reset by the agent
+	reg := new(api.AgentServiceRegistration)
+	reg.ID = ms.svc.ID
+	reg.Name = ms.svc.Service
+	reg.Tags = tags
+	reg.Port = ms.svc.Port
+	reg.Address = ms.svc.Address
+	reg.Kind = ms.svc.Kind
+	reg.TaggedAddresses = ms.svc.TaggedAddresses
+	reg.Meta = ms.svc.Meta
+	reg.Weights = &ms.svc.Weights
+	reg.Proxy = ms.svc.Proxy
+	reg.Connect = ms.svc.Connect
+	// the agent never returns the sidecar definition as part of the parent service, so it must be added back in order
+	// for the sidecar to be kept in step with its parent
+	if ms.sidecar != nil {
+		reg.Connect = new(api.AgentServiceConnect)
+		if ms.svc.Connect != nil {
+			*reg.Connect = *ms.svc.Connect
+		}
+		reg.Connect.SidecarService = cloneServiceRegistration(ms.sidecar)
 	}
+	// always set EnableTagOverride to true
+	reg.EnableTagOverride = true
 
-	ms.pushNotification(NotificationEventManagedServiceTagsRemoved, ms.buildUpdate(err))
-
-	ms.mu.RUnlock()
-
-	if err == nil {
-		err = ms.ForceRefresh()
+	// enforce desired state, if any
+	if ms.desired != nil {
+		ms.desired.apply(reg)
 	}
 
-	return removed, err
-}
+	if missing {
+		ms.log.Info("Upstream service is gone, redefining full service")
+		checks := ms.checks
+		if checks == nil {
+			checks = ms.baseChecks
+		}
+		reg.Checks = make(api.AgentServiceChecks, len(checks))
+		for i, check := range checks {
+			c := *check
+			if c.DeregisterCriticalServiceAfter == "" {
+				c.DeregisterCriticalServiceAfter = ms.deregisterAfter
+			}
+			reg.Checks[i] = &c
+		}
+	}
 
-// ForceRefresh attempts an immediate internal state refresh, blocking until attempt has been completed.
-func (ms *ManagedService) ForceRefresh() error {
-	if !ms.Running() {
-		return errors.New("managed service is not running")
+	ctx, span := startSpan(ctx, ms.tracer, TraceSpanAgentServiceRegister, TraceAttributeServiceID.String(reg.ID))
+	err = ms.client.Agent().ServiceRegisterOpts(reg, api.ServiceRegisterOpts{}.WithContext(ctx))
+	endSpan(span, err)
+	if err != nil {
+		ms.log.Error("Error registering service", LogKeyError, err)
 	}
-	ms.logf(true, "ForceRefresh() - request received...")
-	ch := make(chan error, 1)
-	defer close(ch)
-	ms.forceRefresh <- ch
-	return <-ch
-}
 
-// buildUpdate constructs a notification update type
-//
-// caller must hold lock
-func (ms *ManagedService) buildUpdate(err error) ManagedServiceUpdate {
-	return ManagedServiceUpdate{
-		ServiceID:     ms.svc.ID,
-		ServiceName:   ms.svc.Service,
-		LastRefreshed: ms.localRefreshed,
-		Error:         err,
+	if missing && ms.metrics != nil {
+		ms.metrics.ServiceReRegistered(ms.serviceID, err)
 	}
-}
 
-// pushNotification constructs and then pushes a new notification to currently registered recipients based on the
-// current state of the service.
-func (ms *ManagedService) pushNotification(ev NotificationEvent, up ManagedServiceUpdate) {
-	ms.sendNotification(NotificationSourceManagedService, ev, up)
+	return err
 }
 
-// setState updates the internal state value and pushes a notification of change
+// ensureSidecar verifies the sidecar service is still registered with the agent, re-registering the service along with
+// its sidecar if not.
 //
 // caller must hold full lock
-func (ms *ManagedService) setState(state ManagedServiceState) {
-	var ev NotificationEvent
+func (ms *ManagedService) ensureSidecar(ctx context.Context) error {
+	var err error
 
-	if ms.state == state {
-		return
+	id := ms.sidecarServiceID()
+
+	if _, _, err = ms.client.Agent().Service(id, ms.qo.WithContext(ctx)); err == nil {
+		return nil
+	} else if !IsNotFoundError(err) {
+		ms.log.Error("Error fetching sidecar service", "sidecar_id", id, LogKeyError, err)
+		return err
 	}
 
-	switch state {
-	case ManagedServiceStateRunning:
-		ev = NotificationEventManagedServiceRunning
-	case ManagedServiceStateStopped:
-		ev = NotificationEventManagedServiceStopped
-	case ManagedServiceStateShutdowned:
-		ev = NotificationEventManagedServiceShutdowned
+	ms.log.Warn("Sidecar service is missing, attempting to re-register", "sidecar_id", id)
 
-	default:
-		panic(fmt.Sprintf("unkonwn state %d (%[1]s) seen", state))
+	if err = ms.registerService(ctx, true, ms.svc.Tags); err != nil {
+		ms.log.Error("Failed to re-register service with sidecar", LogKeyError, err)
+	} else {
+		ms.log.Info("Service successfully re-registered with sidecar")
 	}
 
-	ms.state = state
+	ms.pushNotification(NotificationEventManagedServiceSidecarMissing, ms.buildUpdate(err))
 
-	ms.pushNotification(ev, ms.buildUpdate(nil))
+	return err
 }
 
-func (ms *ManagedService) logf(debug bool, f string, v ...interface{}) {
-	if ms.logger == nil || debug && !ms.dbg {
-		return
+// sidecarServiceID returns the id of the sidecar service, defaulting to the id the agent would assign
+func (ms *ManagedService) sidecarServiceID() string {
+	if ms.sidecar == nil {
+		return ""
+	}
+	if ms.sidecar.ID != "" {
+		return ms.sidecar.ID
 	}
-	ms.logger.Printf(f, v...)
+	return ms.serviceID + ServiceSidecarIDSuffix
 }
 
-func (ms *ManagedService) waitForStop() error {
-	drop := make(chan error, 1)
-	ms.stop <- drop
-	err := <-drop
-	close(drop)
-	return err
-}
+// startDrain attempts to take the service out of rotation using the configured drain mode
+//
+// caller must hold full lock
+func (ms *ManagedService) startDrain(ctx context.Context) error {
+	var err error
 
-func (ms *ManagedService) findAgentService(ctx context.Context) (*api.AgentService, *api.QueryMeta, error) {
-	return ms.client.Agent().Service(ms.serviceID, ms.qo.WithContext(ctx))
-}
+	switch ms.drainMode {
+	case ManagedServiceDrainModeMaintenance:
+		sctx, span := startSpan(ctx, ms.tracer, TraceSpanAgentMaintenanceEnable, TraceAttributeServiceID.String(ms.serviceID))
+		err = ms.client.Agent().EnableServiceMaintenanceOpts(ms.serviceID, ServiceDrainReason, ms.qo.WithContext(sctx))
+		endSpan(span, err)
+		if err == nil {
+			ms.maint = true
+			ms.maintReason = ServiceDrainReason
+		}
 
-// findHealth must be used behind a lock
-func (ms *ManagedService) findHealth(ctx context.Context) (*api.ServiceEntry, *api.QueryMeta, error) {
-	var (
-		svcs []*api.ServiceEntry
-		qm   *api.QueryMeta
-		err  error
-	)
-	if svcs, qm, err = ms.client.Health().ServiceMultipleTags(ms.svc.Service, nil, false, ms.qo.WithContext(ctx)); err != nil {
-		return nil, qm, fmt.Errorf("error fetching service health: %s", err)
-	}
-	if svc, ok := SpecificServiceEntry(ms.serviceID, svcs); ok {
-		return svc, qm, nil
+	case ManagedServiceDrainModeCritical:
+		reg := new(api.AgentCheckRegistration)
+		reg.ID = ServiceDrainCheckIDPrefix + ms.serviceID
+		reg.Name = "Service Draining"
+		reg.Notes = ServiceDrainReason
+		reg.ServiceID = ms.serviceID
+		reg.TTL = (ms.drainGrace + ms.rttl).String()
+		reg.Status = api.HealthCritical
+		sctx, span := startSpan(ctx, ms.tracer, TraceSpanAgentCheckRegister, TraceAttributeServiceID.String(ms.serviceID))
+		err = ms.client.Agent().CheckRegisterOpts(reg, ms.qo.WithContext(sctx))
+		endSpan(span, err)
 	}
-	return nil, qm, fmt.Errorf("no service with name %q with id %q found", ms.svc.Service, ms.svc.ID)
-}
 
-// findCatalog must be used behind a lock
-func (ms *ManagedService) findCatalog(ctx context.Context) (*api.CatalogService, *api.QueryMeta, error) {
-	var (
-		svcs []*api.CatalogService
-		qm   *api.QueryMeta
-		err  error
-	)
-	if svcs, qm, err = ms.client.Catalog().ServiceMultipleTags(ms.svc.Service, nil, ms.qo.WithContext(ctx)); err != nil {
-		return nil, nil, fmt.Errorf("error fetching service from catalog: %s", err)
-	}
-	if svc, ok := SpecificCatalogService(ms.serviceID, svcs); ok {
-		return svc, qm, nil
-	}
-	return nil, qm, fmt.Errorf("no service with name %q with id %q found", ms.svc.Service, ms.serviceID)
+	return err
 }
 
-// fetchChecks must be used behind a lock
-func (ms *ManagedService) fetchChecks(ctx context.Context) (api.HealthChecks, *api.QueryMeta, error) {
+// waitForDrain blocks until the drain grace period has elapsed, or until the service is no longer seen as passing if
+// configured to do so.  Returns true if the service was seen leaving rotation.
+func (ms *ManagedService) waitForDrain() bool {
 	var (
-		checks api.HealthChecks
-		qm     *api.QueryMeta
-		err    error
-	)
-	if checks, qm, err = ms.client.Health().Checks(ms.svc.Service, ms.qo.WithContext(ctx)); err != nil {
-		return nil, qm, nil
-	}
-	return SpecificChecks(ms.serviceID, checks), qm, nil
-}
+		poll *time.Ticker
+		pc   <-chan time.Time
 
-// refreshService will refresh the internal representation of the service.  If fetch fails it will attempt to
-// re-register the service based on the last-known definition, returning an error if that itself fails.
-//
-// caller must hold full lock
-func (ms *ManagedService) refreshService(ctx context.Context) (*api.QueryMeta, error) {
-	var (
-		svc *api.AgentService
-		qm  *api.QueryMeta
-		err error
+		grace = time.NewTimer(ms.drainGrace)
 	)
 
-	ms.logf(true, "refreshService() - Refreshing local service...")
+	defer grace.Stop()
 
-	if svc, qm, err = ms.findAgentService(ctx); err != nil {
-		if IsNotFoundError(err) {
-			ms.logf(false, "refreshService() - Received 404 not found, attempting to re-register...")
+	if ms.drainWaitForHealth {
+		poll = time.NewTicker(serviceDrainPollInterval)
+		defer poll.Stop()
+		pc = poll.C
+	}
 
-			ms.pushNotification(NotificationEventManagedServiceMissing, ms.buildUpdate(err))
+	for {
+		select {
+		case <-grace.C:
+			ms.log.Debug("Drain grace period elapsed", "grace", ms.drainGrace)
+			return false
 
-			if err = ms.registerService(true, ms.svc.Tags); err != nil {
-				ms.logf(false, "refreshService() - Failed to re-register service: %s")
-			} else if svc, qm, err = ms.findAgentService(ctx); err != nil {
-				ms.logf(false, "refreshService() - Failed to locate re-registered service: %s", err)
-			} else {
-				ms.logf(false, "refreshService() - Service successfully re-registered")
+		case <-pc:
+			ms.mu.RLock()
+			ctx, cancel := context.WithTimeout(context.Background(), ms.rttl)
+			svcs, _, err := ms.client.Health().ServiceMultipleTags(ms.svc.Service, nil, true, ms.qo.WithContext(ctx))
+			cancel()
+			ms.mu.RUnlock()
+			if err != nil {
+				ms.log.Error("Error querying service health", LogKeyError, err)
+			} else if _, ok := SpecificServiceEntry(ms.serviceID, svcs); !ok {
+				ms.log.Debug("Service is no longer seen as passing")
+				return true
 			}
-
-		} else {
-			ms.logf(false, "refreshService() - Error fetching service from node: %s", err)
 		}
 	}
+}
 
-	if svc == nil && err == nil {
-		err = errors.New("unable to locate service after re-register")
+// drain will take the service out of rotation and wait for it to drain, per configured drain mode.  It does nothing
+// if the drain mode is ManagedServiceDrainModeNone.
+func (ms *ManagedService) drain() {
+	if ms.drainMode == ManagedServiceDrainModeNone {
+		return
 	}
 
-	if err == nil {
-		ms.svc = svc
-		ms.localRefreshed = time.Now()
+	ms.log.Info("Draining service", "mode", ms.drainMode.String(), "grace", ms.drainGrace)
+
+	ctx, cancel := context.WithTimeout(context.Background(), ms.rttl)
+	ms.mu.Lock()
+	err := ms.startDrain(ctx)
+	ms.pushNotification(NotificationEventManagedServiceDrainStarted, ms.buildUpdate(err))
+	ms.mu.Unlock()
+	cancel()
 
-		ms.logf(true, "refreshService() - Service refreshed: %v", svc)
+	if err != nil {
+		// if we were unable to take the service out of rotation there is no point in waiting
+		ms.log.Error("Error taking service out of rotation, skipping wait", LogKeyError, err)
+		return
 	}
 
-	ms.pushNotification(NotificationEventManagedServiceRefreshed, ms.buildUpdate(err))
+	if ms.waitForDrain() {
+		ms.log.Info("Service drained")
+	} else {
+		ms.log.Info("Service drain grace period elapsed")
+	}
 
-	return qm, err
+	ms.mu.RLock()
+	ms.pushNotification(NotificationEventManagedServiceDrainFinished, ms.buildUpdate(nil))
+	ms.mu.RUnlock()
 }
 
-// registerService will attempt to re-push the service to the consul agent
-//
-// caller must hold lock
-func (ms *ManagedService) registerService(missing bool, tags []string) error {
-	var err error
-	ms.logf(false, "registerService() - Registering service with node...")
+// managedServiceWatchKind identifies one of the watch plans run by a ManagedService
+type managedServiceWatchKind uint8
 
-	reg := new(api.AgentServiceRegistration)
-	reg.ID = ms.svc.ID
-	reg.Name = ms.svc.Service
-	reg.Tags = tags
-	reg.Port = ms.svc.Port
-	reg.Address = ms.svc.Address
-	// always set EnableTagOverride to true
-	reg.EnableTagOverride = true
+const (
+	// managedServiceWatchAgent watches this service's own registration on the local agent, keyed by service id, so
+	// that changes made to the service by anyone (including tag edits) are always seen.
+	managedServiceWatchAgent managedServiceWatchKind = iota
+	// managedServiceWatchChecks watches the health checks of the service by name, so that check status transitions
+	// are seen as they happen.
+	managedServiceWatchChecks
+	// managedServiceWatchCatalog watches the health endpoint for all instances of the service by name.  optional.
+	managedServiceWatchCatalog
+)
 
-	if missing {
-		ms.logf(false, "registerService() - Upstream service is gone, redefining full service...")
-		reg.Kind = ms.svc.Kind
-		reg.TaggedAddresses = ms.svc.TaggedAddresses
-		reg.Meta = ms.svc.Meta
-		reg.Weights = &ms.svc.Weights
-		reg.Proxy = ms.svc.Proxy
-		reg.Connect = ms.svc.Connect
+func (k managedServiceWatchKind) String() string {
+	switch k {
+	case managedServiceWatchAgent:
+		return "agent"
+	case managedServiceWatchChecks:
+		return "checks"
+	case managedServiceWatchCatalog:
+		return "catalog"
 
-		// TODO: once possible, build checks based off current upstream definition
-		reg.Checks = ms.baseChecks
+	default:
+		return "UNKNOWN"
 	}
+}
 
-	if err = ms.client.Agent().ServiceRegister(reg); err != nil {
-		ms.logf(false, "registerService() - Error registering service: %s", err)
-	}
+// managedServiceWatchStopped is pushed by runWatchPlan once a watch plan has stopped
+type managedServiceWatchStopped struct {
+	kind managedServiceWatchKind
+	err  error
+}
 
-	return err
+// watchKinds returns the kinds of watch plans this service will run
+func (ms *ManagedService) watchKinds() []managedServiceWatchKind {
+	kinds := []managedServiceWatchKind{managedServiceWatchAgent, managedServiceWatchChecks}
+	if ms.watchCatalog {
+		kinds = append(kinds, managedServiceWatchCatalog)
+	}
+	return kinds
 }
 
-// buildWatchPlan constructs a new watch plan with appropriate handler defined
-func (ms *ManagedService) buildWatchPlan(up chan<- watch.WaitIndexVal) (*watch.Plan, error) {
+// buildWatchPlan constructs a new watch plan of the provided kind with appropriate handler defined.
+func (ms *ManagedService) buildWatchPlan(kind managedServiceWatchKind, up chan<- watch.BlockingParamVal) (*watch.Plan, error) {
 	var (
 		token, datacenter string
 		mu                sync.Mutex
-		last              watch.WaitIndexVal
+		last              watch.BlockingParamVal
+		wp                *watch.Plan
+		err               error
 	)
 
 	// if a query options instance was passed in at construction, extract token and dc values for use in watch plan
@@ -710,7 +2334,19 @@ func (ms *ManagedService) buildWatchPlan(up chan<- watch.WaitIndexVal) (*watch.P
 	}
 
 	// build plan
-	wp, err := WatchServiceMultipleTags(ms.svc.Service, ms.svc.Tags, false, true, token, datacenter)
+	switch kind {
+	case managedServiceWatchAgent:
+		if wp, err = WatchAgentService(ms.serviceID); err == nil {
+			wp.Token = token
+		}
+	case managedServiceWatchChecks:
+		wp, err = WatchChecks(ms.svc.Service, "", true, token, datacenter)
+	case managedServiceWatchCatalog:
+		wp, err = WatchService(ms.svc.Service, "", false, true, token, datacenter)
+
+	default:
+		err = fmt.Errorf("unknown watch kind %d", kind)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -723,22 +2359,19 @@ func (ms *ManagedService) buildWatchPlan(up chan<- watch.WaitIndexVal) (*watch.P
 	//
 	// this is to reduce the number of places we are performing the same work, and it also resets the maintenance timer
 	// to help prevent noise.
+	//
+	// the agent service watch is hash-based while the catalog watch is index-based, so val is handled generically.
 	wp.HybridHandler = func(val watch.BlockingParamVal, _ interface{}) {
-		var (
-			bp watch.WaitIndexVal
-			ok bool
-		)
-
-		// ensure we got expected type of param
-		if bp, ok = val.(watch.WaitIndexVal); !ok {
-			ms.logf(false, "Watcher expected val to be of type watch.WaitIndexVal, saw %T", val)
+		// ensure we got a param
+		if val == nil {
+			ms.log.Warn("Watcher expected val to be defined, saw nil")
 			return
 		}
 
 		// it is entirely possible we'll get an update after the service has been stopped or shutdowned.  there is
 		// nothing to do with these messages.
 		if !ms.Running() {
-			ms.logf(true, "Watcher hit but managed service is %s", ms.State())
+			ms.log.Debug("Watcher hit but managed service is not running", "state", ms.State().String())
 			return
 		}
 
@@ -747,19 +2380,19 @@ func (ms *ManagedService) buildWatchPlan(up chan<- watch.WaitIndexVal) (*watch.P
 		defer mu.Unlock()
 
 		// test for change
-		if bp.Equal(last) {
+		if last != nil && val.Equal(last) {
 			return
 		}
 
 		// record updated index
-		last = bp
+		last = val
 
 		// attempt to push to update chan.  this _should_ never block, if it does complain about it.
 		select {
-		case up <- bp:
+		case up <- val:
 		default:
 			// needed to ensure clean stop
-			ms.logf(false, "Watcher unable to push to update chan")
+			ms.log.Warn("Watcher unable to push to update chan")
 		}
 	}
 
@@ -767,7 +2400,7 @@ func (ms *ManagedService) buildWatchPlan(up chan<- watch.WaitIndexVal) (*watch.P
 }
 
 // runWatchPlan runs the current watch plan, pushing its on-stop error to the provided channel
-func (ms *ManagedService) runWatchPlan(wp *watch.Plan, stopped chan<- error) {
+func (ms *ManagedService) runWatchPlan(kind managedServiceWatchKind, wp *watch.Plan, stopped chan<- managedServiceWatchStopped) {
 	var (
 		logger *log.Logger
 		err    error
@@ -780,11 +2413,7 @@ func (ms *ManagedService) runWatchPlan(wp *watch.Plan, stopped chan<- error) {
 	ms.mu.RUnlock()
 
 	// build logger and run watch plan.
-	if ms.logger == nil {
-		logger = log.New(ioutil.Discard, "", 0)
-	} else {
-		logger = log.New(&loggerWriter{ms.logger}, "", log.LstdFlags)
-	}
+	logger = log.New(&loggerWriter{ms.log.With("watch", kind.String())}, "", 0)
 
 	// blocks until watch plan stops
 	err = wp.RunWithClientAndLogger(ms.client, logger)
@@ -795,20 +2424,20 @@ func (ms *ManagedService) runWatchPlan(wp *watch.Plan, stopped chan<- error) {
 	ms.mu.RUnlock()
 
 	select {
-	case stopped <- err:
+	case stopped <- managedServiceWatchStopped{kind: kind, err: err}:
 
 	default:
-		ms.logf(false, "Watcher unable to push to stopped chan")
+		ms.log.Warn("Watcher unable to push to stopped chan")
 	}
 }
 
-func (ms *ManagedService) buildAndRunWatchPlan(up chan<- watch.WaitIndexVal, stopped chan<- error) (*watch.Plan, error) {
+func (ms *ManagedService) buildAndRunWatchPlan(kind managedServiceWatchKind, up chan<- watch.BlockingParamVal, stopped chan<- managedServiceWatchStopped) (*watch.Plan, error) {
 	var (
 		wp  *watch.Plan
 		err error
 	)
-	if wp, err = ms.buildWatchPlan(up); err == nil {
-		go ms.runWatchPlan(wp, stopped)
+	if wp, err = ms.buildWatchPlan(kind, up); err == nil {
+		go ms.runWatchPlan(kind, wp, stopped)
 	}
 
 	return wp, err
@@ -819,18 +2448,18 @@ func (ms *ManagedService) maintainForceRefresh(ch chan error) {
 	defer cancel()
 	qm, err := ms.refreshService(ctx)
 	if err != nil {
-		ms.logf(false, "maintainLock() - forceRefresh failed. err: %s; QueryMeta: %v", err, qm)
+		ms.log.Error("Force refresh failed", LogKeyError, err, "query_meta", qm)
 	}
 	ch <- err
 }
 
-func (ms *ManagedService) maintainWatchPlanUpdate(idx watch.WaitIndexVal) {
+func (ms *ManagedService) maintainWatchPlanUpdate(idx watch.BlockingParamVal) {
 	ctx, cancel := context.WithTimeout(context.Background(), ms.rttl)
 	defer cancel()
 	if qm, err := ms.refreshService(ctx); err != nil {
-		ms.logf(false, "maintainLock() - Error refreshing service after watch plan update (%d). err %s; QueryMeta %v", idx, err, qm)
+		ms.log.Error("Error refreshing service after watch plan update", "index", idx, LogKeyError, err, "query_meta", qm)
 	} else {
-		ms.logf(true, "maintainLock() - Service updated successfully after watch plan update hit (%d)", idx)
+		ms.log.Debug("Service updated successfully after watch plan update", "index", idx)
 	}
 }
 
@@ -838,84 +2467,117 @@ func (ms *ManagedService) maintainRefreshTimerTick() {
 	ctx, cancel := context.WithTimeout(context.Background(), ms.rttl)
 	defer cancel()
 	if qm, err := ms.refreshService(ctx); err != nil {
-		ms.logf(false, "maintainLock() - refresh failed. err: %s; QueryMeta: %v", err, qm)
+		ms.log.Error("Refresh failed", LogKeyError, err, "query_meta", qm)
+	}
+}
+
+// bootstrapFailed records the permanent error with which the agent rejected the bootstrap registration, ceasing
+// further attempts
+//
+// caller must hold full lock
+func (ms *ManagedService) bootstrapFailed(err error) {
+	ms.bootstrapErr = err
+	ms.log.Error(
+		"Bootstrap registration rejected by agent, will not retry",
+		LogKeyEvent, NotificationEventManagedServiceBootstrapFailed.String(),
+		LogKeyError, err,
+	)
+	ms.pushNotification(NotificationEventManagedServiceBootstrapFailed, ms.buildUpdate(err))
+}
+
+// nextRefreshInterval returns the amount of time to wait before the next refresh, which is shortened while a bootstrap
+// registration is being retried
+func (ms *ManagedService) nextRefreshInterval() time.Duration {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	if ms.bootstrapping && ms.bootstrapErr == nil {
+		return ms.bootstrapRetry
 	}
+	return ms.refreshInterval
 }
 
 func (ms *ManagedService) maintain() {
 	var (
-		wp  *watch.Plan
-		err error
+		kinds = ms.watchKinds()
+		plans = make(map[managedServiceWatchKind]*watch.Plan, len(kinds))
 
-		wpUpdate     = make(chan watch.WaitIndexVal, 5) // TODO: do more fun stuff...
-		wpStopped    = make(chan error, 1)
-		refreshTimer = time.NewTimer(ms.refreshInterval)
+		wpUpdate     = make(chan watch.BlockingParamVal, 5) // TODO: do more fun stuff...
+		wpStopped    = make(chan managedServiceWatchStopped, len(kinds))
+		refreshTimer = time.NewTimer(ms.nextRefreshInterval())
 	)
 
-	ms.logf(true, "maintainLock() - building initial watch plan...")
+	// startPlan builds and runs the watch plan of the provided kind, recording it as nil if that fails
+	startPlan := func(kind managedServiceWatchKind, when string) {
+		wp, err := ms.buildAndRunWatchPlan(kind, wpUpdate, wpStopped)
+		if err != nil {
+			ms.log.Error("Error building watch plan", "watch", kind.String(), "when", when, LogKeyError, err)
+		} else {
+			ms.log.Debug("Watch plan successfully built, running", "watch", kind.String(), "when", when)
+		}
+		plans[kind] = wp
+	}
+
+	ms.log.Debug("Building initial watch plans")
 
-	if wp, err = ms.buildAndRunWatchPlan(wpUpdate, wpStopped); err != nil {
-		ms.logf(false, "maintainLock() - error building initial watch plan: %s", err)
+	for _, kind := range kinds {
+		startPlan(kind, "initially")
 	}
 
-	ms.logf(true, "maintainLock() - entering loop")
+	ms.log.Debug("Entering maintenance loop")
 
 	for {
 		select {
 		case frch := <-ms.forceRefresh:
-			ms.logf(true, "maintainLock() - forceRefresh hit")
+			ms.log.Debug("Force refresh hit")
 
 			ms.mu.Lock()
 			ms.maintainForceRefresh(frch)
+			ms.evaluateHealingRules()
 			ms.mu.Unlock()
 
 			if !refreshTimer.Stop() && len(refreshTimer.C) > 0 {
 				<-refreshTimer.C
 			}
-			refreshTimer.Reset(ms.refreshInterval)
+			refreshTimer.Reset(ms.nextRefreshInterval())
 
-		case err := <-wpStopped:
-			ms.logf(false, "maintainLock() - Watch plan stopped with error: %s", err)
-			if wp, err = ms.buildAndRunWatchPlan(wpUpdate, wpStopped); err != nil {
-				ms.logf(false, "maintainLock() - Error building watch plan after stop: %s", err)
-			} else {
-				ms.logf(false, "maintainLock() - Watch plan successfully rebuilt, running...")
-			}
+		case st := <-wpStopped:
+			ms.log.Warn("Watch plan stopped", "watch", st.kind.String(), LogKeyError, st.err)
+			startPlan(st.kind, "after stop")
 
 		case idx := <-wpUpdate:
-			ms.logf(true, "maintainLock() - Watch plan has received update (idx: %v)", idx)
+			ms.log.Debug("Watch plan has received update", "index", idx)
 
 			ms.mu.Lock()
 			ms.maintainWatchPlanUpdate(idx)
+			ms.evaluateHealingRules()
 			ms.mu.Unlock()
 
 			if !refreshTimer.Stop() && len(refreshTimer.C) > 0 {
 				<-refreshTimer.C
 			}
-			refreshTimer.Reset(ms.refreshInterval)
+			refreshTimer.Reset(ms.nextRefreshInterval())
 
 		case tick := <-refreshTimer.C:
-			ms.logf(true, "maintainLock() - refreshTimer hit (%s)", tick)
+			ms.log.Debug("Refresh interval reached", "tick", tick)
 
 			ms.mu.Lock()
 
 			ms.maintainRefreshTimerTick()
+			ms.evaluateHealingRules()
 
-			// check for the watch plan being nil here, and attempt to start if so
-			if wp == nil {
-				ms.logf(false, "maintainLock() - Watch plan is nil, attempting to rebuild...")
-				if wp, err = ms.buildAndRunWatchPlan(wpUpdate, wpStopped); err != nil {
-					ms.logf(false, "maintainLock() - Error building watch plan during refresh: %s", err)
+			// check for any watch plan being nil here, and attempt to start if so
+			for _, kind := range kinds {
+				if plans[kind] == nil {
+					ms.log.Warn("Watch plan is not running, attempting to rebuild", "watch", kind.String())
+					startPlan(kind, "during refresh")
 				} else {
-					ms.logf(false, "maintainLock() - Watch plan successfully rebuilt")
+					ms.log.Debug("Watch plan is still running", "watch", kind.String())
 				}
-			} else {
-				ms.logf(true, "maintainLock() - Watch plan is still running, hooray.")
 			}
 
 			ms.mu.Unlock()
 
-			refreshTimer.Reset(ms.refreshInterval)
+			refreshTimer.Reset(ms.nextRefreshInterval())
 
 		case drop := <-ms.stop:
 
@@ -925,19 +2587,27 @@ func (ms *ManagedService) maintain() {
 
 			var err error
 
-			ms.logf(false, "maintainLock() - Stop hit")
+			ms.log.Info("Stop hit")
 
-			// stop watcher
-			wp.Stop()
+			// stop watchers
+			running := 0
+			for _, wp := range plans {
+				if wp != nil {
+					wp.Stop()
+					running++
+				}
+			}
 
-			// wait for goroutine to end
-			<-wpStopped
+			// wait for goroutines to end
+			for ; running > 0; running-- {
+				<-wpStopped
+			}
 			close(wpStopped)
 
 			// close update chan, and drain if necessary.
 			close(wpUpdate)
 			if l := len(wpUpdate); l > 0 {
-				ms.logf(true, "maintainShutdown() - Draining wpUpdate (%d items)", l)
+				ms.log.Debug("Draining watch plan update chan", "count", l)
 				for range wpUpdate {
 				}
 			}
@@ -945,17 +2615,26 @@ func (ms *ManagedService) maintain() {
 			// stop timer
 			refreshTimer.Stop()
 
+			// take service out of rotation, if configured to do so
+			ms.drain()
+
 			// deregister service
-			if err = ms.client.Agent().ServiceDeregister(ms.serviceID); err != nil {
-				ms.logf(false, "maintainShutdown() - Error deregistering service: %s", err)
+			_, span := startSpan(context.Background(), ms.tracer, TraceSpanAgentServiceDeregister, TraceAttributeServiceID.String(ms.serviceID))
+			err = ms.client.Agent().ServiceDeregister(ms.serviceID)
+			endSpan(span, err)
+			if err != nil {
+				ms.log.Error("Error deregistering service", LogKeyError, err)
 			} else {
-				ms.logf(false, "maintainShutdown() - Service successfully deregistered")
+				ms.log.Info("Service successfully deregistered")
 			}
 
-			// acquire read lock for notification push
-			ms.mu.RLock()
+			// deregistration removes any maintenance check along with the service, acquire full lock to clear local
+			// state and push notification
+			ms.mu.Lock()
+			ms.maint = false
+			ms.maintReason = ""
 			ms.pushNotification(NotificationEventManagedServiceStopped, ms.buildUpdate(err))
-			ms.mu.RUnlock()
+			ms.mu.Unlock()
 
 			drop <- err
 
@@ -1099,6 +2778,60 @@ func (b *ManagedAgentServiceRegistration) AddAliasCheck(service, node string, in
 	return b.AddCheck(check, fns...)
 }
 
+// SidecarServiceMutator defines a callback that may mutate a sidecar service definition
+type SidecarServiceMutator func(*api.AgentServiceRegistration)
+
+// UpstreamMutator defines a callback that may mutate a new proxy upstream definition
+type UpstreamMutator func(*api.Upstream)
+
+// SetConnectNative marks the service as natively supporting Connect.  A native service may not also define a sidecar
+// service, the agent will reject such a registration.
+func (b *ManagedAgentServiceRegistration) SetConnectNative() *ManagedAgentServiceRegistration {
+	if b.Connect == nil {
+		b.Connect = new(api.AgentServiceConnect)
+	}
+	b.Connect.Native = true
+	return b
+}
+
+// AddSidecarService declares a Connect sidecar proxy to be registered alongside the service, after processing all
+// provided mutators.  If a sidecar has already been declared, the mutators are applied to the existing definition.
+//
+// Any field left empty is filled in by the agent, including ID (defaults to the service's id suffixed with
+// ServiceSidecarIDSuffix) and Port (allocated from the agent's sidecar port range).
+func (b *ManagedAgentServiceRegistration) AddSidecarService(fns ...SidecarServiceMutator) *ManagedAgentServiceRegistration {
+	if b.Connect == nil {
+		b.Connect = new(api.AgentServiceConnect)
+	}
+	if b.Connect.SidecarService == nil {
+		b.Connect.SidecarService = new(api.AgentServiceRegistration)
+	}
+	for _, fn := range fns {
+		fn(b.Connect.SidecarService)
+	}
+	return b
+}
+
+// AddUpstream adds a service upstream to the sidecar proxy after processing all provided mutators, declaring the
+// sidecar if it has not already been.
+func (b *ManagedAgentServiceRegistration) AddUpstream(destinationName string, localBindPort int, fns ...UpstreamMutator) *ManagedAgentServiceRegistration {
+	b.AddSidecarService()
+	sidecar := b.Connect.SidecarService
+	if sidecar.Proxy == nil {
+		sidecar.Proxy = new(api.AgentServiceConnectProxyConfig)
+	}
+	upstream := api.Upstream{
+		DestinationType: api.UpstreamDestTypeService,
+		DestinationName: destinationName,
+		LocalBindPort:   localBindPort,
+	}
+	for _, fn := range fns {
+		fn(&upstream)
+	}
+	sidecar.Proxy.Upstreams = append(sidecar.Proxy.Upstreams, upstream)
+	return b
+}
+
 // Create attempts to first register the configured service with the desired consul agent, then constructs a
 // ManagedService instance for you to use.
 //
@@ -1147,9 +2880,33 @@ func (b *ManagedAgentServiceRegistration) Create(cfg *ManagedServiceConfig) (*Ma
 		}
 	}
 
+	if act.SidecarService == nil && b.Connect != nil && b.Connect.SidecarService != nil {
+		act.SidecarService = b.Connect.SidecarService
+	}
+
 	// ensure EnableTagOverride is true
 	b.EnableTagOverride = true
 
+	// apply default deregister critical after value to any check that does not define one
+	deregisterAfter := ""
+	if act.DeregisterCriticalServiceAfter > 0 {
+		deregisterAfter = act.DeregisterCriticalServiceAfter.String()
+	} else if act.DeregisterCriticalServiceAfter == 0 {
+		deregisterAfter = ServiceDefaultDeregisterCriticalServiceAfter.String()
+	}
+	if b.Check != nil && b.Check.DeregisterCriticalServiceAfter == "" {
+		c := *b.Check
+		c.DeregisterCriticalServiceAfter = deregisterAfter
+		b.Check = &c
+	}
+	for i, check := range b.Checks {
+		if check.DeregisterCriticalServiceAfter == "" {
+			c := *check
+			c.DeregisterCriticalServiceAfter = deregisterAfter
+			b.Checks[i] = &c
+		}
+	}
+
 	if err = act.Client.Agent().ServiceRegister(&b.AgentServiceRegistration); err != nil {
 		return nil, fmt.Errorf("error registering service: %s", err)
 	}
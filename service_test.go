@@ -2,10 +2,16 @@ package consultant_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"regexp"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -321,6 +327,140 @@ func TestManagedService(t *testing.T) {
 		return
 	}
 
+	t.Run("mutate-tags", func(t *testing.T) {
+		var (
+			wg   sync.WaitGroup
+			errs = make(chan error, 10)
+		)
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, err := ms.MutateTags(func(current []string) []string {
+					return append(current, fmt.Sprintf("mutated-%d", i))
+				})
+				errs <- err
+			}(i)
+		}
+
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			if err != nil {
+				t.Logf("Error mutating tags: %s", err)
+				t.Fail()
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		svc, _, err := ms.AgentService(ctx)
+		if err != nil {
+			t.Fatalf("Error fetching service: %s", err)
+		}
+		for i := 0; i < 10; i++ {
+			found := false
+			for _, tag := range svc.Tags {
+				if tag == fmt.Sprintf("mutated-%d", i) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Logf("Expected tag mutated-%d to be present, saw %v", i, svc.Tags)
+				t.Fail()
+			}
+		}
+	})
+
+	t.Run("mutate-tags-accessor", func(t *testing.T) {
+		done := make(chan error, 1)
+		go func() {
+			_, err := ms.MutateTags(func(current []string) []string {
+				// accessors must not deadlock when called from within the mutation
+				return append(current, fmt.Sprintf("state-%s", ms.State()))
+			})
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Logf("Error mutating tags: %s", err)
+				t.Fail()
+			}
+		case <-time.After(5 * time.Second):
+			t.Log("Expected tag mutation calling an accessor to complete within 5 seconds")
+			t.Fail()
+		}
+	})
+
+	if t.Failed() {
+		_ = ms.Shutdown()
+		return
+	}
+
+	t.Run("maintenance", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := ms.EnableMaintenance(ctx, "testing"); err != nil {
+			t.Logf("Error enabling maintenance: %s", err)
+			t.Fail()
+		} else if !ms.InMaintenance() {
+			t.Log("Expected InMaintenance to be true after enable")
+			t.Fail()
+		} else if checks, _, err := ms.Checks(ctx); err != nil {
+			t.Logf("Error fetching checks: %s", err)
+			t.Fail()
+		} else if checks.AggregatedStatus() != api.HealthMaint {
+			t.Logf("Expected aggregated status to be %q, saw %q", api.HealthMaint, checks.AggregatedStatus())
+			t.Fail()
+		}
+		if err := ms.DisableMaintenance(ctx); err != nil {
+			t.Logf("Error disabling maintenance: %s", err)
+			t.Fail()
+		} else if ms.InMaintenance() {
+			t.Log("Expected InMaintenance to be false after disable")
+			t.Fail()
+		}
+	})
+
+	if t.Failed() {
+		_ = ms.Shutdown()
+		return
+	}
+
+	t.Run("external-maintenance", func(t *testing.T) {
+		if err := client.Agent().EnableServiceMaintenance(ms.ServiceID(), "external"); err != nil {
+			t.Fatalf("Error enabling maintenance via agent: %s", err)
+		}
+		if err := ms.ForceRefresh(); err != nil {
+			t.Fatalf("Error refreshing service: %s", err)
+		}
+		if !ms.InMaintenance() {
+			t.Log("Expected InMaintenance to be true after external enable")
+			t.Fail()
+		}
+
+		if err := client.Agent().DisableServiceMaintenance(ms.ServiceID()); err != nil {
+			t.Fatalf("Error disabling maintenance via agent: %s", err)
+		}
+		if err := ms.ForceRefresh(); err != nil {
+			t.Fatalf("Error refreshing service: %s", err)
+		}
+		if ms.InMaintenance() {
+			t.Log("Expected InMaintenance to be false after external disable")
+			t.Fail()
+		}
+	})
+
+	if t.Failed() {
+		_ = ms.Shutdown()
+		return
+	}
+
 	t.Run("re-register", func(t *testing.T) {
 		var (
 			err error
@@ -400,3 +540,846 @@ func TestManagedService(t *testing.T) {
 		_ = ms.Shutdown()
 	})
 }
+
+func TestManagedService_Drain(t *testing.T) {
+	server, client := makeTestServerAndClient(t, nil)
+	defer stopTestServer(server)
+	server.WaitForSerfCheck(t)
+
+	for _, mode := range []consultant.ManagedServiceDrainMode{consultant.ManagedServiceDrainModeMaintenance, consultant.ManagedServiceDrainModeCritical} {
+		t.Run(mode.String(), func(t *testing.T) {
+			var (
+				started, finished uint64
+
+				cfg = new(consultant.ManagedServiceConfig)
+			)
+
+			cfg.DrainMode = mode
+			cfg.DrainGracePeriod = 5 * time.Second
+			cfg.DrainWaitForHealth = true
+
+			ms := newManagedServiceWithServerAndClient(t, nil, cfg, server, client)
+			defer func() { _ = ms.Shutdown() }()
+
+			ms.AttachNotificationHandler("", func(n consultant.Notification) {
+				switch n.Event {
+				case consultant.NotificationEventManagedServiceDrainStarted:
+					if d := n.Data.(consultant.ManagedServiceUpdate); d.Error != nil {
+						t.Logf("Drain start returned error: %s", d.Error)
+						t.Fail()
+					}
+					atomic.StoreUint64(&started, 1)
+				case consultant.NotificationEventManagedServiceDrainFinished:
+					atomic.StoreUint64(&finished, 1)
+				}
+			})
+
+			serviceID := ms.ServiceID()
+
+			if err := ms.Deregister(); err != nil {
+				t.Logf("Error deregistering service: %s", err)
+				t.Fail()
+			}
+
+			for i := 0; i < 10 && atomic.LoadUint64(&finished) == 0; i++ {
+				time.Sleep(100 * time.Millisecond)
+			}
+
+			if atomic.LoadUint64(&started) == 0 || atomic.LoadUint64(&finished) == 0 {
+				t.Log("Expected drain started and finished notifications")
+				t.Fail()
+			}
+
+			if svc, _, err := client.Agent().Service(serviceID, nil); err == nil && svc != nil {
+				t.Logf("Expected service %q to be deregistered after drain", serviceID)
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestManagedService_ReRegisterChecks(t *testing.T) {
+	const (
+		ttlCheckID   = "managed-ttl"
+		extraCheckID = "managed-extra"
+	)
+
+	server, client := makeTestServerAndClient(t, nil)
+	defer stopTestServer(server)
+	server.WaitForSerfCheck(t)
+
+	svcReg := consultant.NewBareManagedAgentServiceRegistration(managedServiceName, managedServicePort)
+	svcReg.Address = getTestLocalAddr(t)
+	svcReg.AddTTLCheck(api.HealthPassing, time.Minute, func(check *api.AgentServiceCheck) {
+		check.CheckID = ttlCheckID
+	})
+	svcReg.AddTCPCheck(10 * time.Second)
+
+	ms := newManagedServiceWithServerAndClient(t, svcReg, nil, server, client)
+	defer func() { _ = ms.Shutdown() }()
+
+	extra := new(api.AgentCheckRegistration)
+	extra.ID = extraCheckID
+	extra.Name = "extra"
+	extra.ServiceID = ms.ServiceID()
+	extra.TCP = fmt.Sprintf("%s:%d", svcReg.Address, managedServicePort)
+	extra.Interval = "10s"
+	if err := client.Agent().CheckRegister(extra); err != nil {
+		t.Fatalf("Error registering extra check: %s", err)
+	}
+
+	if err := ms.ForceRefresh(); err != nil {
+		t.Fatalf("Error refreshing service: %s", err)
+	}
+
+	expected := map[string]bool{
+		ttlCheckID:                    false,
+		extraCheckID:                  false,
+		"service:" + svcReg.ID + ":2": false,
+	}
+
+	if l := len(ms.CheckDefinitions()); l != len(expected) {
+		t.Logf("Expected %d check definitions, saw %d", len(expected), l)
+		t.Fail()
+	}
+
+	if err := client.Agent().ServiceDeregister(ms.ServiceID()); err != nil {
+		t.Fatalf("Error deregistering service: %s", err)
+	}
+
+	if err := ms.ForceRefresh(); err != nil {
+		t.Fatalf("Error refreshing service after deregister: %s", err)
+	}
+
+	checks, err := client.Agent().ChecksWithFilter(fmt.Sprintf("ServiceID == %q", ms.ServiceID()))
+	if err != nil {
+		t.Fatalf("Error fetching checks: %s", err)
+	}
+
+	for id := range checks {
+		expected[id] = true
+	}
+
+	for id, seen := range expected {
+		if !seen {
+			t.Logf("Expected check %q to be present after re-register", id)
+			t.Fail()
+		}
+	}
+}
+
+func TestManagedService_DesiredState(t *testing.T) {
+	server, client := makeTestServerAndClient(t, nil)
+	defer stopTestServer(server)
+	server.WaitForSerfCheck(t)
+
+	cfg := new(consultant.ManagedServiceConfig)
+	cfg.DesiredState = &consultant.ManagedServiceDesiredState{
+		Meta: map[string]string{"owner": "consultant"},
+	}
+
+	ms := newManagedServiceWithServerAndClient(t, nil, cfg, server, client)
+	defer func() { _ = ms.Shutdown() }()
+
+	fetch := func(t *testing.T) *api.AgentService {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		svc, _, err := ms.AgentService(ctx)
+		if err != nil {
+			t.Fatalf("Error fetching service: %s", err)
+		}
+		return svc
+	}
+
+	t.Run("initial", func(t *testing.T) {
+		if svc := fetch(t); svc.Meta["owner"] != "consultant" {
+			t.Logf("Expected meta to be enforced on construction, saw %v", svc.Meta)
+			t.Fail()
+		}
+	})
+
+	t.Run("set-weights", func(t *testing.T) {
+		if err := ms.SetWeights(5, 2); err != nil {
+			t.Fatalf("Error setting weights: %s", err)
+		}
+		if svc := fetch(t); svc.Weights.Passing != 5 || svc.Weights.Warning != 2 {
+			t.Logf("Expected weights {5 2}, saw %+v", svc.Weights)
+			t.Fail()
+		}
+	})
+
+	t.Run("external-drift", func(t *testing.T) {
+		var drifted uint64
+
+		ms.AttachNotificationHandler("", func(n consultant.Notification) {
+			if n.Event == consultant.NotificationEventManagedServiceDrift {
+				atomic.StoreUint64(&drifted, 1)
+			}
+		})
+
+		svc := fetch(t)
+		reg := &api.AgentServiceRegistration{
+			ID:                svc.ID,
+			Name:              svc.Service,
+			Address:           svc.Address,
+			Port:              svc.Port,
+			Tags:              svc.Tags,
+			EnableTagOverride: true,
+			Meta:              map[string]string{"owner": "someone-else"},
+		}
+		if err := client.Agent().ServiceRegister(reg); err != nil {
+			t.Fatalf("Error altering service: %s", err)
+		}
+
+		if err := ms.ForceRefresh(); err != nil {
+			t.Fatalf("Error refreshing service: %s", err)
+		}
+
+		svc = fetch(t)
+		if svc.Meta["owner"] != "consultant" {
+			t.Logf("Expected meta drift to be corrected, saw %v", svc.Meta)
+			t.Fail()
+		}
+		if svc.Weights.Passing != 5 {
+			t.Logf("Expected weights drift to be corrected, saw %+v", svc.Weights)
+			t.Fail()
+		}
+
+		for i := 0; i < 10 && atomic.LoadUint64(&drifted) == 0; i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if atomic.LoadUint64(&drifted) == 0 {
+			t.Log("Expected drift notification")
+			t.Fail()
+		}
+	})
+}
+
+func TestManagedService_AgentWatch(t *testing.T) {
+	server, client := makeTestServerAndClient(t, nil)
+	defer stopTestServer(server)
+	server.WaitForSerfCheck(t)
+
+	ms := newManagedServiceWithServerAndClient(t, nil, nil, server, client)
+	defer func() { _ = ms.Shutdown() }()
+
+	// give watch plan a moment to perform its initial query
+	time.Sleep(time.Second)
+
+	var refreshed uint64
+
+	ms.AttachNotificationHandler("", func(n consultant.Notification) {
+		if n.Event == consultant.NotificationEventManagedServiceRefreshed {
+			atomic.AddUint64(&refreshed, 1)
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	svc, _, err := ms.AgentService(ctx)
+	if err != nil {
+		t.Fatalf("Error fetching service: %s", err)
+	}
+
+	// replace all tags externally.  the watch must not be blinded by this.
+	reg := &api.AgentServiceRegistration{
+		ID:                svc.ID,
+		Name:              svc.Service,
+		Address:           svc.Address,
+		Port:              svc.Port,
+		Tags:              []string{"someone-elses-tag"},
+		EnableTagOverride: true,
+	}
+	if err := client.Agent().ServiceRegister(reg); err != nil {
+		t.Fatalf("Error altering service: %s", err)
+	}
+
+	for i := 0; i < 50 && atomic.LoadUint64(&refreshed) == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if atomic.LoadUint64(&refreshed) == 0 {
+		t.Log("Expected agent watch to trigger a refresh after external tag change")
+		t.Fail()
+	}
+}
+
+func TestManagedAgentServiceRegistration_Connect(t *testing.T) {
+	b := consultant.NewBareManagedAgentServiceRegistration(managedServiceName, managedServicePort).
+		AddUpstream("db", 9191).
+		AddUpstream("cache", 9192, func(u *api.Upstream) { u.Datacenter = "dc2" }).
+		AddSidecarService(func(reg *api.AgentServiceRegistration) { reg.Port = 21000 })
+
+	if b.Connect == nil || b.Connect.SidecarService == nil {
+		t.Fatal("Expected sidecar service to be defined")
+	}
+	sidecar := b.Connect.SidecarService
+	if sidecar.Port != 21000 {
+		t.Logf("Expected sidecar port 21000, saw %d", sidecar.Port)
+		t.Fail()
+	}
+	if sidecar.Proxy == nil || len(sidecar.Proxy.Upstreams) != 2 {
+		t.Fatalf("Expected 2 upstreams, saw %+v", sidecar.Proxy)
+	}
+	if u := sidecar.Proxy.Upstreams[0]; u.DestinationName != "db" || u.LocalBindPort != 9191 {
+		t.Logf("Unexpected first upstream: %+v", u)
+		t.Fail()
+	}
+	if u := sidecar.Proxy.Upstreams[1]; u.DestinationName != "cache" || u.Datacenter != "dc2" {
+		t.Logf("Unexpected second upstream: %+v", u)
+		t.Fail()
+	}
+
+	native := consultant.NewBareManagedAgentServiceRegistration(managedServiceName, managedServicePort).SetConnectNative()
+	if native.Connect == nil || !native.Connect.Native {
+		t.Log("Expected service to be Connect native")
+		t.Fail()
+	}
+}
+
+func TestManagedService_Sidecar(t *testing.T) {
+	server, client := makeTestServerAndClient(t, nil)
+	defer stopTestServer(server)
+	server.WaitForSerfCheck(t)
+
+	b := consultant.NewBareManagedAgentServiceRegistration(managedServiceName, managedServicePort).
+		AddUpstream("db", 9191)
+
+	ms := newManagedServiceWithServerAndClient(t, b, nil, server, client)
+	defer func() { _ = ms.Shutdown() }()
+
+	sidecarID := ms.SidecarServiceID()
+	if sidecarID != ms.ServiceID()+consultant.ServiceSidecarIDSuffix {
+		t.Fatalf("Unexpected sidecar service id: %q", sidecarID)
+	}
+
+	sidecarRegistered := func(t *testing.T) bool {
+		svcs, err := client.Agent().Services()
+		if err != nil {
+			t.Fatalf("Error fetching services: %s", err)
+		}
+		_, ok := svcs[sidecarID]
+		return ok
+	}
+
+	if !sidecarRegistered(t) {
+		t.Fatal("Expected sidecar service to be registered")
+	}
+
+	t.Run("sidecar-missing", func(t *testing.T) {
+		if err := client.Agent().ServiceDeregister(sidecarID); err != nil {
+			t.Fatalf("Error deregistering sidecar: %s", err)
+		}
+		if err := ms.ForceRefresh(); err != nil {
+			t.Fatalf("Error refreshing service: %s", err)
+		}
+		if !sidecarRegistered(t) {
+			t.Log("Expected sidecar service to be re-registered")
+			t.Fail()
+		}
+	})
+
+	t.Run("service-missing", func(t *testing.T) {
+		if err := client.Agent().ServiceDeregister(ms.ServiceID()); err != nil {
+			t.Fatalf("Error deregistering service: %s", err)
+		}
+		if err := ms.ForceRefresh(); err != nil {
+			t.Fatalf("Error refreshing service: %s", err)
+		}
+		if !sidecarRegistered(t) {
+			t.Log("Expected sidecar service to be re-registered along with its parent")
+			t.Fail()
+		}
+	})
+}
+
+func TestManagedService_Health(t *testing.T) {
+	server, client := makeTestServerAndClient(t, nil)
+	defer stopTestServer(server)
+	server.WaitForSerfCheck(t)
+
+	b := consultant.NewBareManagedAgentServiceRegistration(managedServiceName, managedServicePort).
+		AddTTLCheck(api.HealthPassing, time.Minute)
+
+	ms := newManagedServiceWithServerAndClient(t, b, nil, server, client)
+	defer func() { _ = ms.Shutdown() }()
+
+	if h := ms.Health(); h != api.HealthPassing {
+		t.Fatalf("Expected initial health to be %q, saw %q", api.HealthPassing, h)
+	}
+
+	t.Run("maintenance-excluded", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := ms.EnableMaintenance(ctx, "testing"); err != nil {
+			t.Fatalf("Error enabling maintenance: %s", err)
+		}
+		defer func() { _ = ms.DisableMaintenance(ctx) }()
+		if err := ms.ForceRefresh(); err != nil {
+			t.Fatalf("Error refreshing service: %s", err)
+		}
+		if h := ms.Health(); h != api.HealthPassing {
+			t.Logf("Expected maintenance check to be excluded from health, saw %q", h)
+			t.Fail()
+		}
+	})
+
+	var (
+		mu      sync.Mutex
+		changed []consultant.ManagedServiceUpdate
+	)
+
+	ms.AttachNotificationHandler("", func(n consultant.Notification) {
+		if n.Event != consultant.NotificationEventManagedServiceHealthChanged {
+			return
+		}
+		mu.Lock()
+		changed = append(changed, n.Data.(consultant.ManagedServiceUpdate))
+		mu.Unlock()
+	})
+
+	checkID := fmt.Sprintf("service:%s", ms.ServiceID())
+
+	for _, status := range []string{api.HealthWarning, api.HealthCritical} {
+		status := status
+		t.Run(status, func(t *testing.T) {
+			output := fmt.Sprintf("now %s", status)
+			if err := client.Agent().UpdateTTL(checkID, output, status); err != nil {
+				t.Fatalf("Error updating check: %s", err)
+			}
+
+			var up *consultant.ManagedServiceUpdate
+			for i := 0; i < 50 && up == nil; i++ {
+				time.Sleep(100 * time.Millisecond)
+				mu.Lock()
+				for i := range changed {
+					if changed[i].Health == status {
+						up = &changed[i]
+					}
+				}
+				mu.Unlock()
+			}
+
+			if up == nil {
+				t.Fatalf("Expected health transition to %q", status)
+			}
+			if len(up.HealthChanges) != 1 {
+				t.Fatalf("Expected 1 check change, saw %+v", up.HealthChanges)
+			}
+			if c := up.HealthChanges[0]; c.CheckID != checkID || c.Status != status || c.Output != output {
+				t.Logf("Unexpected check change: %+v", c)
+				t.Fail()
+			}
+			if ms.Health() != status {
+				t.Logf("Expected health to be %q, saw %q", status, ms.Health())
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestManagedService_HealingRules(t *testing.T) {
+	server, client := makeTestServerAndClient(t, nil)
+	defer stopTestServer(server)
+	server.WaitForSerfCheck(t)
+
+	var (
+		hooked   uint64
+		exited   uint64
+		notified uint64
+
+		// set once the exit notification has been handled, and checked when exit is called
+		exitNotified      uint32
+		exitBeforeHandled uint32
+	)
+
+	cfg := new(consultant.ManagedServiceConfig)
+	cfg.RefreshInterval = api.ReadableDuration(500 * time.Millisecond)
+	cfg.HealingRules = []consultant.ManagedServiceHealingRule{
+		{
+			After:  time.Second,
+			Action: consultant.ManagedServiceHealingActionHook,
+			Hook: func(_ context.Context, _ *consultant.ManagedService, _ string) error {
+				atomic.AddUint64(&hooked, 1)
+				return nil
+			},
+		},
+		{
+			After:    time.Second,
+			Action:   consultant.ManagedServiceHealingActionExit,
+			ExitCode: 3,
+			Exit: func(code int) {
+				if atomic.LoadUint32(&exitNotified) == 0 {
+					atomic.StoreUint32(&exitBeforeHandled, 1)
+				}
+				atomic.StoreUint64(&exited, uint64(code))
+			},
+		},
+		{
+			After:  2 * time.Second,
+			Action: consultant.ManagedServiceHealingActionReRegisterCheck,
+		},
+	}
+
+	b := consultant.NewBareManagedAgentServiceRegistration(managedServiceName, managedServicePort).
+		AddTTLCheck(api.HealthPassing, time.Minute)
+
+	ms := newManagedServiceWithServerAndClient(t, b, cfg, server, client)
+	defer func() { _ = ms.Shutdown() }()
+
+	ms.AttachNotificationHandler("", func(n consultant.Notification) {
+		if n.Event == consultant.NotificationEventManagedServiceHealingAction {
+			if up, ok := n.Data.(consultant.ManagedServiceUpdate); ok && up.Healing != nil &&
+				up.Healing.Action == consultant.ManagedServiceHealingActionExit {
+				// delay handling, so an exit that does not wait for it would be seen
+				time.Sleep(100 * time.Millisecond)
+				atomic.StoreUint32(&exitNotified, 1)
+			}
+			atomic.AddUint64(&notified, 1)
+		}
+	})
+
+	checkID := fmt.Sprintf("service:%s", ms.ServiceID())
+	if err := client.Agent().UpdateTTL(checkID, "broken", api.HealthCritical); err != nil {
+		t.Fatalf("Error updating check: %s", err)
+	}
+
+	for i := 0; i < 100 && atomic.LoadUint64(&notified) < 3; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if n := atomic.LoadUint64(&hooked); n != 1 {
+		t.Logf("Expected hook to be called once, saw %d", n)
+		t.Fail()
+	}
+	if code := atomic.LoadUint64(&exited); code != 3 {
+		t.Logf("Expected exit with code 3, saw %d", code)
+		t.Fail()
+	}
+	if atomic.LoadUint32(&exitBeforeHandled) != 0 {
+		t.Log("Expected exit healing notification to be handled before exiting")
+		t.Fail()
+	}
+	if n := atomic.LoadUint64(&notified); n < 3 {
+		t.Logf("Expected 3 healing notifications, saw %d", n)
+		t.Fail()
+	}
+
+	checks, err := client.Agent().Checks()
+	if err != nil {
+		t.Fatalf("Error fetching checks: %s", err)
+	}
+	if c, ok := checks[checkID]; !ok || c.Status != api.HealthPassing {
+		t.Logf("Expected check to be re-registered as passing, saw %+v", c)
+		t.Fail()
+	}
+}
+
+func TestManagedService_SweepOrphansFailure(t *testing.T) {
+	const (
+		selfID = "self"
+		addr   = "10.0.0.1"
+	)
+
+	var (
+		deregistered int32
+
+		orphanID = consultant.ReplaceSlugs(consultant.ServiceDefaultIDFormat, consultant.SlugParams{Name: managedServiceName, Addr: addr})
+	)
+
+	// minimal agent that knows of this service and a single orphan, but refuses to deregister it
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/agent/service/" + selfID:
+			_ = json.NewEncoder(w).Encode(&api.AgentService{ID: selfID, Service: managedServiceName, Address: addr, Port: managedServicePort})
+		case "/v1/agent/services":
+			_ = json.NewEncoder(w).Encode(map[string]*api.AgentService{
+				selfID:   {ID: selfID, Service: managedServiceName, Address: addr, Port: managedServicePort},
+				orphanID: {ID: orphanID, Service: managedServiceName, Address: addr, Port: managedServicePort},
+			})
+		case "/v1/agent/checks":
+			checks := make(map[string]*api.AgentCheck)
+			if strings.Contains(r.URL.Query().Get("filter"), orphanID) {
+				checks["service:"+orphanID] = &api.AgentCheck{CheckID: "service:" + orphanID, ServiceID: orphanID, Status: api.HealthCritical, Output: "ttl expired"}
+			}
+			_ = json.NewEncoder(w).Encode(checks)
+		case "/v1/agent/service/deregister/" + orphanID:
+			atomic.AddInt32(&deregistered, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	apiCfg := api.DefaultConfig()
+	apiCfg.Address = srv.URL
+	apiClient, err := api.NewClient(apiCfg)
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	cfg := new(consultant.ManagedServiceConfig)
+	cfg.ID = selfID
+	cfg.Client = apiClient
+	cfg.SweepOrphansConfirmWindow = 10 * time.Millisecond
+
+	ms, err := consultant.NewManagedService(cfg)
+	if err != nil {
+		t.Fatalf("Error creating managed service: %s", err)
+	}
+	defer func() { _ = ms.Shutdown() }()
+
+	var swept uint64
+	ms.AttachNotificationHandler("", func(n consultant.Notification) {
+		atomic.AddUint64(&swept, 1)
+	}, consultant.WithNotificationEvents(consultant.NotificationEventManagedServiceOrphanSwept))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ids, err := ms.SweepOrphans(ctx)
+	if len(ids) != 0 {
+		t.Logf("Expected no orphans to be reported as swept, saw %v", ids)
+		t.Fail()
+	}
+	var oerr *consultant.ManagedServiceOrphanError
+	if !errors.As(err, &oerr) || oerr.ServiceID != orphanID {
+		t.Logf("Expected error for orphan %q, saw: %v", orphanID, err)
+		t.Fail()
+	}
+	if n := atomic.LoadInt32(&deregistered); n != 1 {
+		t.Logf("Expected 1 deregister attempt, saw %d", n)
+		t.Fail()
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadUint64(&swept); n != 0 {
+		t.Logf("Expected no swept notifications, saw %d", n)
+		t.Fail()
+	}
+}
+
+func TestManagedService_Bootstrap(t *testing.T) {
+	t.Run("agent-available", func(t *testing.T) {
+		server, client := makeTestServerAndClient(t, nil)
+		defer stopTestServer(server)
+		server.WaitForSerfCheck(t)
+
+		cfg := new(consultant.ManagedServiceConfig)
+		cfg.Client = client.Client
+		cfg.Logger = log.New(os.Stdout, "---> managed service ", log.LstdFlags)
+		cfg.Debug = true
+		cfg.Registration = &api.AgentServiceRegistration{
+			ID:   "bootstrapped",
+			Name: managedServiceName,
+			Port: managedServicePort,
+			Check: &api.AgentServiceCheck{
+				TTL:    "1m",
+				Status: api.HealthPassing,
+			},
+		}
+
+		ms, err := consultant.NewManagedService(cfg)
+		if err != nil {
+			t.Fatalf("Error creating managed service: %s", err)
+		}
+		defer func() { _ = ms.Shutdown() }()
+
+		if ms.Bootstrapping() {
+			t.Log("Expected bootstrap registration to have completed during construction")
+			t.Fail()
+		}
+		if ms.ServiceID() != "bootstrapped" {
+			t.Logf("Expected service id to be taken from registration, saw %q", ms.ServiceID())
+			t.Fail()
+		}
+
+		svcs, err := client.Agent().Services()
+		if err != nil {
+			t.Fatalf("Error fetching services: %s", err)
+		}
+		if _, ok := svcs["bootstrapped"]; !ok {
+			t.Log("Expected service to have been registered")
+			t.Fail()
+		}
+		if defs := ms.CheckDefinitions(); len(defs) != 1 {
+			t.Logf("Expected registration check to be used as base check, saw %+v", defs)
+			t.Fail()
+		}
+	})
+
+	t.Run("agent-unavailable", func(t *testing.T) {
+		apiCfg := api.DefaultConfig()
+		apiCfg.Address = fmt.Sprintf("127.0.0.1:%d", consultant.RandomLocalPort())
+		apiClient, err := api.NewClient(apiCfg)
+		if err != nil {
+			t.Fatalf("Error creating client: %s", err)
+		}
+
+		cfg := new(consultant.ManagedServiceConfig)
+		cfg.Client = apiClient
+		cfg.BootstrapRetryInterval = 100 * time.Millisecond
+		cfg.Registration = &api.AgentServiceRegistration{
+			Name: managedServiceName,
+			Port: managedServicePort,
+		}
+
+		ms, err := consultant.NewManagedService(cfg)
+		if err != nil {
+			t.Fatalf("Expected construction to succeed without an agent, saw: %s", err)
+		}
+		defer func() { _ = ms.Shutdown() }()
+
+		if !ms.Bootstrapping() {
+			t.Log("Expected service to still be bootstrapping")
+			t.Fail()
+		}
+		if !ms.Running() {
+			t.Log("Expected service to be running")
+			t.Fail()
+		}
+		if ms.ServiceID() == "" {
+			t.Log("Expected service id to be generated")
+			t.Fail()
+		}
+	})
+
+	t.Run("agent-rejects", func(t *testing.T) {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("Permission denied"))
+		}))
+		defer srv.Close()
+
+		apiCfg := api.DefaultConfig()
+		apiCfg.Address = srv.URL
+		apiClient, err := api.NewClient(apiCfg)
+		if err != nil {
+			t.Fatalf("Error creating client: %s", err)
+		}
+
+		bus := consultant.NewNotificationBus(nil, false)
+		defer bus.Close()
+
+		cfg := new(consultant.ManagedServiceConfig)
+		cfg.Client = apiClient
+		cfg.BootstrapRetryInterval = 100 * time.Millisecond
+		cfg.NotificationBus = bus
+		cfg.Registration = &api.AgentServiceRegistration{
+			Name: managedServiceName,
+			Port: managedServicePort,
+		}
+
+		ms, err := consultant.NewManagedService(cfg)
+		if err == nil {
+			_ = ms.Shutdown()
+			t.Fatal("Expected construction to fail when the agent rejects the bootstrap registration")
+		}
+		var se api.StatusError
+		if !errors.As(err, &se) || se.Code != http.StatusForbidden {
+			t.Logf("Expected construction error to wrap a 403 response, saw: %s", err)
+			t.Fail()
+		}
+		if n := atomic.LoadInt32(&calls); n != 1 {
+			t.Logf("Expected exactly 1 request to the agent, saw %d", n)
+			t.Fail()
+		}
+		if producers := bus.Producers(); len(producers) != 0 {
+			t.Logf("Expected failed construction to leave the bus, saw producers %v", producers)
+			t.Fail()
+		}
+	})
+}
+
+func TestManagedService_Orphans(t *testing.T) {
+	server, client := makeTestServerAndClient(t, nil)
+	defer stopTestServer(server)
+	server.WaitForSerfCheck(t)
+
+	addr := getTestLocalAddr(t)
+
+	register := func(t *testing.T, status string) string {
+		id := consultant.ReplaceSlugs(consultant.ServiceDefaultIDFormat, consultant.SlugParams{Name: managedServiceName, Addr: addr})
+		reg := &api.AgentServiceRegistration{
+			ID:      id,
+			Name:    managedServiceName,
+			Address: addr,
+			Port:    managedServicePort,
+			Check: &api.AgentServiceCheck{
+				TTL:    "1m",
+				Status: status,
+			},
+		}
+		if err := client.Agent().ServiceRegister(reg); err != nil {
+			t.Fatalf("Error registering service: %s", err)
+		}
+		return id
+	}
+
+	orphanID := register(t, api.HealthCritical)
+	if err := client.Agent().UpdateTTL("service:"+orphanID, "ttl expired", api.HealthCritical); err != nil {
+		t.Fatalf("Error updating orphan check: %s", err)
+	}
+	aliveID := register(t, api.HealthPassing)
+	// checks that have yet to run start out critical without output
+	startingID := register(t, api.HealthCritical)
+
+	cfg := new(consultant.ManagedServiceConfig)
+	cfg.SweepOrphans = true
+	cfg.SweepOrphansConfirmWindow = time.Second
+
+	b := consultant.NewBareManagedAgentServiceRegistration(managedServiceName, managedServicePort).
+		AddTTLCheck(api.HealthPassing, time.Minute)
+	b.Address = addr
+
+	ms := newManagedServiceWithServerAndClient(t, b, cfg, server, client)
+	defer func() { _ = ms.Shutdown() }()
+
+	t.Run("sweep", func(t *testing.T) {
+		var (
+			svcs map[string]*api.AgentService
+			err  error
+		)
+
+		// the startup sweep runs in the background once the confirmation window has passed
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			if svcs, err = client.Agent().Services(); err != nil {
+				t.Fatalf("Error fetching services: %s", err)
+			}
+			if _, ok := svcs[orphanID]; !ok || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(250 * time.Millisecond)
+		}
+
+		if _, ok := svcs[orphanID]; ok {
+			t.Logf("Expected orphaned service %q to have been deregistered", orphanID)
+			t.Fail()
+		}
+		if _, ok := svcs[aliveID]; !ok {
+			t.Logf("Expected passing service %q to have been left alone", aliveID)
+			t.Fail()
+		}
+		if _, ok := svcs[startingID]; !ok {
+			t.Logf("Expected service %q with checks yet to run to have been left alone", startingID)
+			t.Fail()
+		}
+		if _, ok := svcs[ms.ServiceID()]; !ok {
+			t.Log("Expected managed service to still be registered")
+			t.Fail()
+		}
+	})
+
+	t.Run("deregister-critical-after", func(t *testing.T) {
+		defs := ms.CheckDefinitions()
+		if len(defs) != 1 {
+			t.Fatalf("Expected 1 check definition, saw %d", len(defs))
+		}
+		if d := defs[0].DeregisterCriticalServiceAfter; d != consultant.ServiceDefaultDeregisterCriticalServiceAfter.String() {
+			t.Logf("Expected default deregister critical after value, saw %q", d)
+			t.Fail()
+		}
+	})
+}
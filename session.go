@@ -9,6 +9,7 @@ import (
 
 	"github.com/hashicorp/consul/api"
 	"github.com/myENA/go-helpers"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ManagedSessionState uint8
@@ -103,10 +104,42 @@ type ManagedSessionConfig struct {
 	// Enables debug-level logging
 	Debug bool
 
+	// StructuredLogger [optional]
+	//
+	// Optionally specify a levelled, structured logger to use in place of Logger.  If defined, Logger and Debug are
+	// ignored.  When used within a CandidateConfig, it is only used by the candidate's session.
+	StructuredLogger StructuredLogger
+
 	// Client [optional]
 	//
 	// API client to use for managing this session.  If left empty, a new one will be created using api.DefaultConfig()
 	Client *api.Client
+
+	// NotificationBus [optional]
+	//
+	// If defined, the session will register itself with this bus on construction, using its name as producer identity.  When
+	// used within a CandidateConfig, both the candidate and its session are registered, the candidate using its ID and
+	// KV key.  Should an identity already be registered, a random suffix is appended.
+	NotificationBus *NotificationBus
+
+	// Metrics [optional]
+	//
+	// If defined, renew attempts and dropped notifications will be recorded here.  When used within a
+	// CandidateConfig, election changes are also recorded.
+	Metrics MetricsRecorder
+
+	// TracerProvider [optional]
+	//
+	// If defined, calls made to Consul to create, renew, and destroy the session will be wrapped in spans from this
+	// provider.  When used within a CandidateConfig, key acquisition and deletion are also traced.
+	TracerProvider trace.TracerProvider
+
+	// TraceContext [optional]
+	//
+	// If defined, spans created by the session's background routines will be started from this context, allowing them
+	// to be parented to a span of your own.  Only its values are used, its cancellation is ignored.  When used within
+	// a CandidateConfig, key acquisition and deletion spans are also started from this context.
+	TraceContext context.Context
 }
 
 // ManagedSession
@@ -119,6 +152,8 @@ type ManagedSession struct {
 	wo         *api.WriteOptions
 	def        *api.SessionEntry
 	requestTTL time.Duration
+	tracer     trace.Tracer
+	traceCtx   context.Context
 
 	id            string
 	ttl           time.Duration
@@ -128,8 +163,8 @@ type ManagedSession struct {
 	stop  chan chan error
 	state ManagedSessionState
 
-	logger Logger
-	dbg    bool
+	// busID is the producer identity this session joined its NotificationBus with, if any
+	busID string
 }
 
 // NewManagedSession attempts to create a managed session instance for your immediate use.
@@ -144,9 +179,19 @@ func NewManagedSession(conf *ManagedSessionConfig) (*ManagedSession, error) {
 		conf = new(ManagedSessionConfig)
 	}
 
-	ms.dbg = conf.Debug
-	ms.logger = conf.Logger
-	ms.notifierBase = newNotifierBase(ms.logger, ms.dbg)
+	ms.notifierBase = newNotifierBase(buildStructuredLogger(
+		conf.StructuredLogger,
+		conf.Logger,
+		conf.Debug,
+		LogKeyComponent, LogComponentManagedSession,
+	))
+	ms.metrics = conf.Metrics
+	ms.tracer = newTracer(conf.TracerProvider)
+	if conf.TraceContext != nil {
+		ms.traceCtx = context.WithoutCancel(conf.TraceContext)
+	} else {
+		ms.traceCtx = context.Background()
+	}
 	ms.stop = make(chan chan error, 1)
 	ms.qo = conf.QueryOptions
 	ms.wo = conf.WriteOptions
@@ -202,7 +247,7 @@ func NewManagedSession(conf *ManagedSessionConfig) (*ManagedSession, error) {
 
 	if ms.def.Node == "" {
 		if ms.def.Node, err = ms.client.Agent().NodeName(); err != nil {
-			ms.logf(false, "node name not set and unable to determine name of local agent node: %s", err)
+			ms.log.Warn("Node name not set and unable to determine name of local agent node", LogKeyError, err)
 		}
 	}
 
@@ -210,15 +255,21 @@ func NewManagedSession(conf *ManagedSessionConfig) (*ManagedSession, error) {
 		ms.def.Name = buildDefaultSessionName(ms.def)
 	}
 
+	ms.log = ms.log.With(LogKeySessionName, ms.def.Name)
+
+	if conf.NotificationBus != nil {
+		ms.busID = conf.NotificationBus.join(ms.def.Name, ms)
+	}
+
 	if conf.StartImmediately {
-		ms.logf(true, "StartImmediately enabled")
+		ms.log.Debug("StartImmediately enabled")
 		if err := ms.Run(); err != nil {
+			leaveNotificationBus(conf.NotificationBus, ms.busID)
 			return nil, err
 		}
 	}
 
-	ms.logf(true, "Lock timeout: %s", ms.def.TTL)
-	ms.logf(true, "Renew interval: %s", ms.renewInterval)
+	ms.log.Debug("Session configured", "ttl", ms.def.TTL, "renew_interval", ms.renewInterval)
 
 	return ms, nil
 }
@@ -307,13 +358,13 @@ func (ms *ManagedSession) Run() error {
 
 	if ms.state == ManagedSessionStateShutdowned {
 		// if local state is shutdowned, do not allow further run attempts
-		ms.logf(false, "Run() called but I am shutdowned")
+		ms.log.Info("Run() called but I am shutdowned")
 		return errors.New("managed session is shutdowned")
 	}
 
 	if ms.state == ManagedSessionStateRunning {
 		// if our state is already running, just continue to do so.
-		ms.logf(true, "Run() called but I'm already running")
+		ms.log.Debug("Run() called but I'm already running")
 		return nil
 	}
 
@@ -322,13 +373,9 @@ func (ms *ManagedSession) Run() error {
 
 	// try to create session immediately
 	if err := ms.create(); err != nil {
-		ms.logf(
-			false,
-			"Unable to perform initial session creation, will try again in \"%d\" seconds: %s",
-			int64(ms.renewInterval.Seconds()),
-			err)
+		ms.log.Warn("Unable to perform initial session creation, will try again", "retry_in", ms.renewInterval, LogKeyError, err)
 	} else {
-		ms.logf(true, "Run() - New upstream session created: %s", ms.id)
+		ms.log.Debug("New upstream session created", LogKeySessionID, ms.id)
 	}
 
 	go ms.maintain()
@@ -341,13 +388,13 @@ func (ms *ManagedSession) Stop() error {
 	ms.mu.Lock()
 
 	if ms.state == ManagedSessionStateShutdowned {
-		ms.logf(false, "Stop() called but i am shutdowned")
+		ms.log.Info("Stop() called but I am shutdowned")
 		ms.mu.Unlock()
 		return errors.New("managed session is shutdowned")
 	}
 
 	if ms.state == ManagedSessionStateStopped {
-		ms.logf(true, "Stop() called but I'm already stopped")
+		ms.log.Debug("Stop() called but I'm already stopped")
 		ms.mu.Unlock()
 		return nil
 	}
@@ -364,7 +411,7 @@ func (ms *ManagedSession) Shutdown() error {
 	ms.mu.Lock()
 	if ms.state == ManagedSessionStateShutdowned {
 		ms.mu.Unlock()
-		ms.logf(true, "Shutdown() called but I'm already shutdowned")
+		ms.log.Debug("Shutdown() called but I'm already shutdowned")
 		return nil
 	}
 
@@ -403,13 +450,6 @@ func (ms *ManagedSession) State() ManagedSessionState {
 	return s
 }
 
-func (ms *ManagedSession) logf(debug bool, f string, v ...interface{}) {
-	if ms.logger == nil || (debug && !ms.dbg) {
-		return
-	}
-	ms.logger.Printf(f, v...)
-}
-
 func (ms *ManagedSession) waitForStop() error {
 	stopped := make(chan error, 1)
 	ms.stop <- stopped
@@ -472,19 +512,22 @@ func (ms *ManagedSession) setState(state ManagedSessionState) {
 func (ms *ManagedSession) create() error {
 	var err error
 
-	ms.logf(true, "create() - Attempting to create upstream session...")
+	ms.log.Debug("Attempting to create upstream session")
 
 	se := *ms.def
 
-	ctx, cancel := context.WithTimeout(context.Background(), ms.requestTTL)
+	ctx, span := startSpan(ms.traceCtx, ms.tracer, TraceSpanSessionCreate, TraceAttributeSessionName.String(se.Name))
+	ctx, cancel := context.WithTimeout(ctx, ms.requestTTL)
 	defer cancel()
 	ms.id, _, err = ms.client.Session().Create(&se, ms.wo.WithContext(ctx))
+	span.SetAttributes(TraceAttributeSessionID.String(ms.id))
+	endSpan(span, err)
 
 	if err == nil {
 		ms.lastRenewed = time.Now()
-		ms.logf(true, "create() - Upstream session created: %s", ms.id)
+		ms.log.Debug("Upstream session created", LogKeySessionID, ms.id)
 	} else {
-		ms.logf(false, "create() - Error creating upstream session: %s", err)
+		ms.log.Error("Error creating upstream session", LogKeyError, err)
 	}
 
 	up := ms.buildUpdate(err)
@@ -507,21 +550,34 @@ func (ms *ManagedSession) renew() {
 		err error
 	)
 
-	ctx, cancel := context.WithTimeout(context.Background(), ms.requestTTL)
+	ctx, span := startSpan(
+		ms.traceCtx,
+		ms.tracer,
+		TraceSpanSessionRenew,
+		TraceAttributeSessionID.String(ms.id),
+		TraceAttributeSessionName.String(ms.def.Name),
+	)
+	ctx, cancel := context.WithTimeout(ctx, ms.requestTTL)
 	defer cancel()
+	start := time.Now()
 	if se, _, err = ms.client.Session().Renew(ms.id, ms.wo.WithContext(ctx)); err != nil {
-		ms.logf(false, "renew() - Error refreshing upstream session (%s), clearing local references...", err)
+		ms.log.Error("Error refreshing upstream session, clearing local references", LogKeySessionID, ms.id, LogKeyError, err)
 		ms.id = ""
 	} else if se != nil {
-		ms.logf(true, "renew() - Upstream session renewed")
+		ms.log.Debug("Upstream session renewed", LogKeySessionID, se.ID)
 		ms.id = se.ID
 		ms.lastRenewed = time.Now()
 	} else {
-		ms.logf(false, "renew() - Upstream session not found, will recreate on next pass")
+		ms.log.Warn("Upstream session not found, will recreate on next pass", LogKeySessionID, ms.id)
 		ms.id = ""
 		err = errors.New("upstream session not found")
 	}
 
+	endSpan(span, err)
+	if ms.metrics != nil {
+		ms.metrics.SessionRenewed(ms.def.Name, time.Since(start), err)
+	}
+
 	up := ms.buildUpdate(err)
 
 	ms.pushNotification(NotificationEventManagedSessionRenew, up)
@@ -536,15 +592,23 @@ func (ms *ManagedSession) destroy() error {
 	}
 
 	sid := ms.id
-	ctx, cancel := context.WithTimeout(context.Background(), ms.requestTTL)
+	ctx, span := startSpan(
+		ms.traceCtx,
+		ms.tracer,
+		TraceSpanSessionDestroy,
+		TraceAttributeSessionID.String(sid),
+		TraceAttributeSessionName.String(ms.def.Name),
+	)
+	ctx, cancel := context.WithTimeout(ctx, ms.requestTTL)
 	defer cancel()
 	_, err := ms.client.Session().Destroy(sid, ms.wo.WithContext(ctx))
+	endSpan(span, err)
 	ms.id = ""
 	ms.lastRenewed = time.Time{}
 	if err != nil {
-		ms.logf(false, "destroy() - Error destroying upstream session: %s", err)
+		ms.log.Error("Error destroying upstream session", LogKeySessionID, sid, LogKeyError, err)
 	} else {
-		ms.logf(true, "destroy() - Upstream session destroyed")
+		ms.log.Debug("Upstream session destroyed", LogKeySessionID, sid)
 	}
 
 	up := ms.buildUpdate(err)
@@ -562,12 +626,11 @@ func (ms *ManagedSession) maintainTick() {
 		if !ms.lastRenewed.IsZero() && time.Now().Sub(ms.lastRenewed) > ms.ttl {
 			// if we have a session but the last time we were able to successfully renew it was beyond the TTL,
 			// attempt to destroy and allow re-creation down below
-			ms.logf(
-				true,
-				"maintainTick() - Last renewed time (%s) is > ttl (%s), expiring upstream session %q...",
-				ms.lastRenewed.Format(time.RFC822),
-				ms.ttl,
-				ms.id,
+			ms.log.Debug(
+				"Last renewed time is beyond ttl, expiring upstream session",
+				LogKeySessionID, ms.id,
+				"last_renewed", ms.lastRenewed.Format(time.RFC822),
+				"ttl", ms.ttl,
 			)
 			_ = ms.destroy()
 		} else {
@@ -586,7 +649,7 @@ func (ms *ManagedSession) maintainTick() {
 //
 // caller must hold full lock
 func (ms *ManagedSession) doStop() error {
-	ms.logf(false, "doStop() - Stopping session...")
+	ms.log.Info("Stopping session")
 
 	var err error
 
@@ -598,7 +661,7 @@ func (ms *ManagedSession) doStop() error {
 	// set our state to stopped, preventing further interaction.
 	ms.setState(ManagedSessionStateStopped)
 
-	ms.logf(false, "doStop() - ManagedSession stopped")
+	ms.log.Info("ManagedSession stopped")
 
 	return err
 }
@@ -626,13 +689,13 @@ func (ms *ManagedSession) maintain() {
 		select {
 		case tick = <-intervalTimer.C:
 			ms.mu.Lock()
-			ms.logf(true, "maintainLock() - intervalTimer hit (%s)", tick)
+			ms.log.Debug("Renew interval reached", "tick", tick)
 			ms.maintainTick()
 			ms.mu.Unlock()
 			intervalTimer.Reset(ms.renewInterval)
 
 		case drop = <-ms.stop:
-			ms.logf(false, "maintainLock() - explicit stop called")
+			ms.log.Info("Explicit stop called")
 			return
 		}
 	}
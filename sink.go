@@ -0,0 +1,670 @@
+package consultant
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SerializedNotification is the form a Notification takes when written to an external sink
+type SerializedNotification struct {
+	ID         string      `json:"id"`
+	CauseID    string      `json:"cause_id,omitempty"`
+	Originated int64       `json:"originated"`
+	Source     string      `json:"source"`
+	Event      string      `json:"event"`
+	Producer   string      `json:"producer,omitempty"`
+	Sequence   uint64      `json:"sequence,omitempty"`
+	Data       interface{} `json:"data"`
+}
+
+// SerializeNotification converts a Notification into a type suitable for encoding.  Data is walked and any error
+// values found within it are replaced with their message, as most error implementations do not otherwise survive
+// encoding.
+func SerializeNotification(n Notification) SerializedNotification {
+	return SerializedNotification{
+		ID:         n.ID,
+		CauseID:    n.CauseID,
+		Originated: n.Originated,
+		Source:     n.Source.String(),
+		Event:      n.Event.String(),
+		Producer:   n.Producer,
+		Sequence:   n.Sequence,
+		Data:       flattenNotificationData(reflect.ValueOf(n.Data)),
+	}
+}
+
+// MarshalNotification returns the JSON encoding of the serialized form of the provided Notification
+func MarshalNotification(n Notification) ([]byte, error) {
+	return json.Marshal(SerializeNotification(n))
+}
+
+var (
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// flattenNotificationData recursively rebuilds v, replacing errors with their message.  Structs are converted to maps
+// keyed as encoding/json would key them.
+func flattenNotificationData(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	if v.Type().Implements(errorType) {
+		if (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr) && v.IsNil() {
+			return nil
+		}
+		return v.Interface().(error).Error()
+	}
+
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return flattenNotificationData(v.Elem())
+
+	case reflect.Struct:
+		out := make(map[string]interface{}, v.NumField())
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, omitEmpty, skip := notificationDataFieldName(f)
+			if skip {
+				continue
+			}
+			fv := v.Field(i)
+			if omitEmpty && fv.IsZero() {
+				continue
+			}
+			out[name] = flattenNotificationData(fv)
+		}
+		return out
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		// byte slices are left for encoding/json to base64 encode
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		out := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out[i] = flattenNotificationData(v.Index(i))
+		}
+		return out
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[fmt.Sprint(iter.Key().Interface())] = flattenNotificationData(iter.Value())
+		}
+		return out
+
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return nil
+
+	default:
+		return v.Interface()
+	}
+}
+
+// notificationDataFieldName returns the key encoding/json would use for the provided field
+func notificationDataFieldName(f reflect.StructField) (name string, omitEmpty bool, skip bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	name = f.Name
+	if tag != "" {
+		parts := strings.Split(tag, ",")
+		if parts[0] != "" {
+			name = parts[0]
+		}
+		for _, opt := range parts[1:] {
+			if opt == "omitempty" {
+				omitEmpty = true
+			}
+		}
+	}
+	return name, omitEmpty, false
+}
+
+const (
+	NotificationWebhookDefaultBatchSize     = 50
+	NotificationWebhookDefaultMaxPending    = 1000
+	NotificationWebhookDefaultFlushInterval = time.Second
+	NotificationWebhookDefaultMaxRetries    = 3
+	NotificationWebhookDefaultRetryBackoff  = 500 * time.Millisecond
+)
+
+// NotificationWebhookConfig describes the basis for a new NotificationWebhook instance
+type NotificationWebhookConfig struct {
+	// URL [required]
+	//
+	// Endpoint batches of notifications will be POSTed to as a JSON array of SerializedNotification
+	URL string
+
+	// Header [optional]
+	//
+	// Additional headers to send with each request
+	Header http.Header
+
+	// HTTPClient [optional]
+	//
+	// Client to send requests with.  Defaults to a client with a 10 second timeout.
+	HTTPClient *http.Client
+
+	// BatchSize [optional]
+	//
+	// Maximum number of notifications to send per request.  A request is sent immediately once this many are
+	// pending.  Defaults to NotificationWebhookDefaultBatchSize.
+	BatchSize int
+
+	// MaxPending [optional]
+	//
+	// Maximum number of notifications held while waiting to be sent.  Once reached, the oldest pending notification is
+	// dropped to make room for each new one.  Defaults to NotificationWebhookDefaultMaxPending, and is never less than
+	// BatchSize.
+	MaxPending int
+
+	// FlushInterval [optional]
+	//
+	// Maximum amount of time a notification may wait for its batch to fill before being sent.  Defaults to
+	// NotificationWebhookDefaultFlushInterval.
+	FlushInterval time.Duration
+
+	// MaxRetries [optional]
+	//
+	// Number of times a failed request will be retried before the batch is dropped.  Requests are only retried on
+	// transport errors, 429, and 5xx responses.  Defaults to NotificationWebhookDefaultMaxRetries, negative disables.
+	MaxRetries int
+
+	// RetryBackoff [optional]
+	//
+	// Base amount of time to wait between retries, doubled after each attempt.  Defaults to
+	// NotificationWebhookDefaultRetryBackoff.
+	RetryBackoff time.Duration
+
+	// Logger [optional]
+	//
+	// Optionally specify a logger.  No logging will take place if left empty.
+	Logger Logger
+
+	// Debug [optional]
+	//
+	// If true, will enable debug-level logging
+	Debug bool
+
+	// StructuredLogger [optional]
+	//
+	// Optionally specify a levelled, structured logger to use in place of Logger.  If defined, Logger and Debug are
+	// ignored.
+	StructuredLogger StructuredLogger
+}
+
+// NotificationWebhook batches notifications and POSTs them to a remote endpoint.  Its Handle method may be attached
+// to any Notifier.
+type NotificationWebhook struct {
+	mu sync.Mutex
+
+	url     string
+	header  http.Header
+	client  *http.Client
+	size    int
+	max     int
+	flush   time.Duration
+	retries int
+	backoff time.Duration
+
+	log StructuredLogger
+
+	pending []SerializedNotification
+	dropped uint64
+	closed  bool
+	full    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewNotificationWebhook constructs a new NotificationWebhook, starting its periodic flush routine
+func NewNotificationWebhook(cfg *NotificationWebhookConfig) (*NotificationWebhook, error) {
+	if cfg == nil || cfg.URL == "" {
+		return nil, errors.New("URL is required")
+	}
+
+	w := new(NotificationWebhook)
+	w.url = cfg.URL
+	w.header = cfg.Header.Clone()
+	w.log = buildStructuredLogger(
+		cfg.StructuredLogger,
+		cfg.Logger,
+		cfg.Debug,
+		LogKeyComponent, LogComponentNotificationSink,
+		"sink", "webhook",
+	)
+
+	if cfg.HTTPClient != nil {
+		w.client = cfg.HTTPClient
+	} else {
+		w.client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if w.size = cfg.BatchSize; w.size <= 0 {
+		w.size = NotificationWebhookDefaultBatchSize
+	}
+	if w.max = cfg.MaxPending; w.max <= 0 {
+		w.max = NotificationWebhookDefaultMaxPending
+	}
+	if w.max < w.size {
+		w.max = w.size
+	}
+	if w.flush = cfg.FlushInterval; w.flush <= 0 {
+		w.flush = NotificationWebhookDefaultFlushInterval
+	}
+	if cfg.MaxRetries == 0 {
+		w.retries = NotificationWebhookDefaultMaxRetries
+	} else if cfg.MaxRetries > 0 {
+		w.retries = cfg.MaxRetries
+	}
+	if w.backoff = cfg.RetryBackoff; w.backoff <= 0 {
+		w.backoff = NotificationWebhookDefaultRetryBackoff
+	}
+
+	w.pending = make([]SerializedNotification, 0, w.size)
+	w.full = make(chan struct{}, 1)
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+
+	go w.maintain()
+
+	return w, nil
+}
+
+// Handle queues the provided notification, signalling the flush routine to send the pending batch once it is full.
+// If MaxPending notifications are already waiting to be sent, the oldest is dropped.  It never blocks on a request
+// being sent.  It is a NotificationHandler.
+func (w *NotificationWebhook) Handle(n Notification) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	if len(w.pending) >= w.max {
+		w.pending = w.pending[:copy(w.pending, w.pending[1:])]
+		atomic.AddUint64(&w.dropped, 1)
+	}
+	w.pending = append(w.pending, SerializeNotification(n))
+	if len(w.pending) >= w.size {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Dropped returns the number of notifications dropped due to MaxPending having been reached
+func (w *NotificationWebhook) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Flush immediately sends all pending notifications, in batches of no more than BatchSize
+func (w *NotificationWebhook) Flush(ctx context.Context) error {
+	var errs []error
+	for {
+		w.mu.Lock()
+		batch := w.take()
+		w.mu.Unlock()
+		if len(batch) == 0 {
+			return errors.Join(errs...)
+		}
+		if err := w.send(ctx, batch); err != nil {
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				return errors.Join(errs...)
+			}
+		}
+	}
+}
+
+// Close stops the periodic flush routine and sends any pending notifications.  Notifications handled after Close are
+// discarded.
+func (w *NotificationWebhook) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.stop)
+	w.mu.Unlock()
+
+	<-w.done
+
+	return w.Flush(context.Background())
+}
+
+// take returns up to BatchSize of the oldest pending notifications, removing them from the pending queue
+//
+// caller must hold lock
+func (w *NotificationWebhook) take() []SerializedNotification {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	n := len(w.pending)
+	if n > w.size {
+		n = w.size
+	}
+	batch := make([]SerializedNotification, n)
+	copy(batch, w.pending)
+	w.pending = w.pending[:copy(w.pending, w.pending[n:])]
+	return batch
+}
+
+func (w *NotificationWebhook) maintain() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.flush)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.full:
+			if err := w.Flush(context.Background()); err != nil {
+				w.log.Error("Error sending batch of notifications", LogKeyError, err)
+			}
+		case <-ticker.C:
+			if err := w.Flush(context.Background()); err != nil {
+				w.log.Error("Error flushing notifications", LogKeyError, err)
+			}
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// send POSTs the batch, retrying as configured
+func (w *NotificationWebhook) send(ctx context.Context, batch []SerializedNotification) error {
+	b, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("error encoding notifications: %w", err)
+	}
+
+	backoff := w.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(ctx, b)
+		if err == nil {
+			w.log.Debug("Sent notifications", "count", len(batch), "url", w.url)
+			return nil
+		}
+		if !retry || attempt >= w.retries {
+			return fmt.Errorf("error sending %d notifications after %d attempt(s): %w", len(batch), attempt+1, err)
+		}
+
+		w.log.Debug("Send attempt failed, retrying", "attempt", attempt+1, "retry_in", backoff, LogKeyError, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// post makes a single request, returning whether the failure is one that may be retried
+func (w *NotificationWebhook) post(ctx context.Context, b []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(b))
+	if err != nil {
+		return false, err
+	}
+	for k, vs := range w.header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("unexpected response status %q", resp.Status)
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+const (
+	NotificationFileDefaultMaxBytes   = 10 << 20
+	NotificationFileDefaultMaxBackups = 5
+	NotificationFileDefaultMode       = os.FileMode(0600)
+)
+
+// NotificationFileConfig describes the basis for a new NotificationFile instance
+type NotificationFileConfig struct {
+	// Path [required]
+	//
+	// File notifications will be appended to, one JSON encoded SerializedNotification per line
+	Path string
+
+	// MaxBytes [optional]
+	//
+	// Size at which the file is rotated.  Defaults to NotificationFileDefaultMaxBytes.
+	MaxBytes int64
+
+	// MaxBackups [optional]
+	//
+	// Number of rotated files to keep, named Path.1 through Path.N with Path.1 being the most recent.  Defaults to
+	// NotificationFileDefaultMaxBackups, negative keeps none.
+	MaxBackups int
+
+	// Mode [optional]
+	//
+	// Permissions new files are created with.  Defaults to NotificationFileDefaultMode.
+	Mode os.FileMode
+
+	// Logger [optional]
+	//
+	// Optionally specify a logger.  No logging will take place if left empty.
+	Logger Logger
+
+	// Debug [optional]
+	//
+	// If true, will enable debug-level logging
+	Debug bool
+
+	// StructuredLogger [optional]
+	//
+	// Optionally specify a levelled, structured logger to use in place of Logger.  If defined, Logger and Debug are
+	// ignored.
+	StructuredLogger StructuredLogger
+}
+
+// NotificationFile appends notifications to a size-rotated JSON lines file.  Its Handle method may be attached to any
+// Notifier.
+type NotificationFile struct {
+	mu sync.Mutex
+
+	path    string
+	max     int64
+	backups int
+	mode    os.FileMode
+
+	log StructuredLogger
+
+	f    *os.File
+	size int64
+}
+
+// NewNotificationFile constructs a new NotificationFile, opening or creating the file at the configured path
+func NewNotificationFile(cfg *NotificationFileConfig) (*NotificationFile, error) {
+	if cfg == nil || cfg.Path == "" {
+		return nil, errors.New("path is required")
+	}
+
+	nf := new(NotificationFile)
+	nf.path = cfg.Path
+	nf.log = buildStructuredLogger(
+		cfg.StructuredLogger,
+		cfg.Logger,
+		cfg.Debug,
+		LogKeyComponent, LogComponentNotificationSink,
+		"sink", "file",
+	)
+
+	if nf.max = cfg.MaxBytes; nf.max <= 0 {
+		nf.max = NotificationFileDefaultMaxBytes
+	}
+	if cfg.MaxBackups == 0 {
+		nf.backups = NotificationFileDefaultMaxBackups
+	} else if cfg.MaxBackups > 0 {
+		nf.backups = cfg.MaxBackups
+	}
+	if nf.mode = cfg.Mode; nf.mode == 0 {
+		nf.mode = NotificationFileDefaultMode
+	}
+
+	if err := nf.open(); err != nil {
+		return nil, err
+	}
+
+	return nf, nil
+}
+
+// Handle appends the provided notification to the file, rotating it first if necessary.  It is a
+// NotificationHandler.
+func (nf *NotificationFile) Handle(n Notification) {
+	b, err := MarshalNotification(n)
+	if err != nil {
+		nf.log.Error("Error encoding notification", "notification_id", n.ID, LogKeyEvent, n.Event.String(), LogKeyError, err)
+		return
+	}
+	b = append(b, '\n')
+	if err := nf.write(b); err != nil {
+		nf.log.Error("Error writing notification", "notification_id", n.ID, LogKeyEvent, n.Event.String(), LogKeyError, err)
+	}
+}
+
+// Close closes the underlying file.  Notifications handled after Close are discarded.
+func (nf *NotificationFile) Close() error {
+	nf.mu.Lock()
+	defer nf.mu.Unlock()
+	if nf.f == nil {
+		return nil
+	}
+	err := nf.f.Close()
+	nf.f = nil
+	return err
+}
+
+func (nf *NotificationFile) write(b []byte) error {
+	nf.mu.Lock()
+	defer nf.mu.Unlock()
+
+	if nf.f == nil {
+		return errors.New("file is closed")
+	}
+
+	var rerr error
+	if nf.size > 0 && nf.size+int64(len(b)) > nf.max {
+		if rerr = nf.rotate(); rerr != nil {
+			rerr = fmt.Errorf("error rotating %q: %w", nf.path, rerr)
+			// rotate reopens path even when it fails, only give up if that too failed
+			if nf.f == nil {
+				return rerr
+			}
+		}
+	}
+
+	n, err := nf.f.Write(b)
+	nf.size += int64(n)
+	return errors.Join(rerr, err)
+}
+
+// open opens or creates the file at path for appending
+//
+// caller must hold lock
+func (nf *NotificationFile) open() error {
+	f, err := os.OpenFile(nf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, nf.mode)
+	if err != nil {
+		return fmt.Errorf("error opening %q: %w", nf.path, err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("error reading info for %q: %w", nf.path, err)
+	}
+	nf.f = f
+	nf.size = fi.Size()
+	return nil
+}
+
+// rotate shifts each existing backup up by one, discarding the oldest, and moves the current file into the first
+// backup slot before opening a new file.  path is reopened for appending even if shifting fails, so that a failed
+// rotation does not leave the sink unable to write.
+//
+// caller must hold lock
+func (nf *NotificationFile) rotate() error {
+	if err := nf.f.Close(); err != nil {
+		nf.log.Error("Error closing file", "path", nf.path, LogKeyError, err)
+	}
+	nf.f = nil
+
+	err := nf.shift()
+	if err != nil {
+		nf.log.Error("Error rotating file, reopening for append", "path", nf.path, LogKeyError, err)
+	} else {
+		nf.log.Debug("Rotated file", "path", nf.path)
+	}
+
+	return errors.Join(err, nf.open())
+}
+
+// shift performs the renames, or removal if no backups are kept, required by rotate
+func (nf *NotificationFile) shift() error {
+	if nf.backups == 0 {
+		if err := os.Remove(nf.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	for i := nf.backups - 1; i > 0; i-- {
+		from := fmt.Sprintf("%s.%d", nf.path, i)
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", nf.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(nf.path, nf.path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
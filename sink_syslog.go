@@ -0,0 +1,163 @@
+//go:build !windows && !plan9
+
+package consultant
+
+import (
+	"fmt"
+	"log/syslog"
+	"reflect"
+)
+
+const (
+	NotificationSyslogDefaultPriority = syslog.LOG_INFO | syslog.LOG_DAEMON
+	NotificationSyslogDefaultTag      = "consultant"
+)
+
+// NotificationSyslogConfig describes the basis for a new NotificationSyslog instance
+type NotificationSyslogConfig struct {
+	// Network and Addr [optional]
+	//
+	// Remote syslog daemon to write to.  If left empty, the local syslog daemon is used.
+	Network string
+	Addr    string
+
+	// Priority [optional]
+	//
+	// Facility and severity notifications are written with.  Notifications whose Data carries a non-nil Error are
+	// always written with LOG_ERR severity under the same facility.  Defaults to NotificationSyslogDefaultPriority.
+	Priority syslog.Priority
+
+	// Tag [optional]
+	//
+	// Tag to write messages with.  Defaults to NotificationSyslogDefaultTag.
+	Tag string
+
+	// Logger [optional]
+	//
+	// Optionally specify a logger.  No logging will take place if left empty.
+	Logger Logger
+
+	// Debug [optional]
+	//
+	// If true, will enable debug-level logging
+	Debug bool
+
+	// StructuredLogger [optional]
+	//
+	// Optionally specify a levelled, structured logger to use in place of Logger.  If defined, Logger and Debug are
+	// ignored.
+	StructuredLogger StructuredLogger
+}
+
+// NotificationSyslog writes each notification to syslog as a JSON encoded SerializedNotification.  Its Handle method
+// may be attached to any Notifier.
+type NotificationSyslog struct {
+	w        *syslog.Writer
+	priority syslog.Priority
+
+	log StructuredLogger
+}
+
+// NewNotificationSyslog constructs a new NotificationSyslog, connecting to the configured syslog daemon
+func NewNotificationSyslog(cfg *NotificationSyslogConfig) (*NotificationSyslog, error) {
+	var err error
+
+	if cfg == nil {
+		cfg = new(NotificationSyslogConfig)
+	}
+
+	ns := new(NotificationSyslog)
+	ns.log = buildStructuredLogger(
+		cfg.StructuredLogger,
+		cfg.Logger,
+		cfg.Debug,
+		LogKeyComponent, LogComponentNotificationSink,
+		"sink", "syslog",
+	)
+
+	if ns.priority = cfg.Priority; ns.priority == 0 {
+		ns.priority = NotificationSyslogDefaultPriority
+	}
+	tag := cfg.Tag
+	if tag == "" {
+		tag = NotificationSyslogDefaultTag
+	}
+
+	if ns.w, err = syslog.Dial(cfg.Network, cfg.Addr, ns.priority, tag); err != nil {
+		return nil, fmt.Errorf("error connecting to syslog: %w", err)
+	}
+
+	return ns, nil
+}
+
+// Handle writes the provided notification to syslog.  It is a NotificationHandler.
+func (ns *NotificationSyslog) Handle(n Notification) {
+	b, err := MarshalNotification(n)
+	if err != nil {
+		ns.log.Error("Error encoding notification", "notification_id", n.ID, LogKeyEvent, n.Event.String(), LogKeyError, err)
+		return
+	}
+
+	if notificationDataError(n.Data) != nil {
+		err = ns.w.Err(string(b))
+	} else {
+		err = ns.write(string(b))
+	}
+	if err != nil {
+		ns.log.Error("Error writing notification", "notification_id", n.ID, LogKeyEvent, n.Event.String(), LogKeyError, err)
+	}
+}
+
+// Close closes the connection to the syslog daemon
+func (ns *NotificationSyslog) Close() error {
+	return ns.w.Close()
+}
+
+// write writes m with the configured severity
+func (ns *NotificationSyslog) write(m string) error {
+	switch ns.priority & 0x07 {
+	case syslog.LOG_EMERG:
+		return ns.w.Emerg(m)
+	case syslog.LOG_ALERT:
+		return ns.w.Alert(m)
+	case syslog.LOG_CRIT:
+		return ns.w.Crit(m)
+	case syslog.LOG_ERR:
+		return ns.w.Err(m)
+	case syslog.LOG_WARNING:
+		return ns.w.Warning(m)
+	case syslog.LOG_NOTICE:
+		return ns.w.Notice(m)
+	case syslog.LOG_DEBUG:
+		return ns.w.Debug(m)
+
+	default:
+		return ns.w.Info(m)
+	}
+}
+
+// notificationDataError returns the value of the top-level Error field of the provided notification data, if there is
+// one
+func notificationDataError(d interface{}) error {
+	if err, ok := d.(error); ok {
+		return err
+	}
+	v := reflect.ValueOf(d)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	f := v.FieldByName("Error")
+	if !f.IsValid() || !f.Type().Implements(errorType) {
+		return nil
+	}
+	if (f.Kind() == reflect.Interface || f.Kind() == reflect.Ptr) && f.IsNil() {
+		return nil
+	}
+	return f.Interface().(error)
+}
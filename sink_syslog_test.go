@@ -0,0 +1,56 @@
+//go:build !windows && !plan9
+
+package consultant_test
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/myENA/consultant/v2"
+)
+
+func TestNotificationSyslog(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error creating listener: %s", err)
+	}
+	defer conn.Close()
+
+	ns, err := consultant.NewNotificationSyslog(&consultant.NotificationSyslogConfig{
+		Network: "udp",
+		Addr:    conn.LocalAddr().String(),
+		Tag:     "consultant-test",
+	})
+	if err != nil {
+		t.Fatalf("Error creating syslog sink: %s", err)
+	}
+	defer ns.Close()
+
+	ns.Handle(consultant.Notification{
+		ID:     "notification",
+		Source: consultant.NotificationSourceCandidate,
+		Event:  consultant.NotificationEventCandidateLostElection,
+		Data:   consultant.CandidateUpdate{ID: "candidate", Error: errors.New("session went away")},
+	})
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 4096)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Error reading syslog message: %s", err)
+	}
+	msg := string(buf[:n])
+
+	// facility daemon (3) * 8 + severity err (3)
+	if !strings.HasPrefix(msg, "<27>") {
+		t.Logf("Expected message with error to be written at LOG_ERR, saw %q", msg)
+		t.Fail()
+	}
+	if !strings.Contains(msg, "consultant-test") || !strings.Contains(msg, `"error":"session went away"`) {
+		t.Logf("Unexpected message: %q", msg)
+		t.Fail()
+	}
+}
@@ -0,0 +1,306 @@
+package consultant_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/myENA/consultant/v2"
+)
+
+func TestSerializeNotification(t *testing.T) {
+	n := consultant.Notification{
+		ID:     "id",
+		Source: consultant.NotificationSourceCandidate,
+		Event:  consultant.NotificationEventCandidateLostElection,
+		Data: consultant.CandidateUpdate{
+			ID:    "candidate",
+			State: consultant.CandidateStateRunning,
+			Error: errors.New("session went away"),
+		},
+	}
+
+	b, err := consultant.MarshalNotification(n)
+	if err != nil {
+		t.Fatalf("Error marshalling notification: %s", err)
+	}
+
+	out := make(map[string]interface{})
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("Error unmarshalling notification: %s", err)
+	}
+
+	if out["source"] != consultant.NotificationSourceCandidate.String() || out["event"] != consultant.NotificationEventCandidateLostElection.String() {
+		t.Logf("Unexpected source or event: %v %v", out["source"], out["event"])
+		t.Fail()
+	}
+	data, ok := out["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected data to be an object, saw %T", out["data"])
+	}
+	if data["id"] != "candidate" || data["error"] != "session went away" {
+		t.Logf("Unexpected data: %v", data)
+		t.Fail()
+	}
+
+	n.Data = consultant.CandidateUpdate{ID: "candidate"}
+	if sn := consultant.SerializeNotification(n); sn.Data.(map[string]interface{})["error"] != nil {
+		t.Logf("Expected nil error to remain nil, saw %v", sn.Data)
+		t.Fail()
+	}
+}
+
+func TestNotificationWebhook(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts uint64
+		received []consultant.SerializedNotification
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// fail every other request to exercise retries
+		if atomic.AddUint64(&attempts, 1)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var batch []consultant.SerializedNotification
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Logf("Error decoding batch: %s", err)
+			t.Fail()
+		}
+		mu.Lock()
+		received = append(received, batch...)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	wh, err := consultant.NewNotificationWebhook(&consultant.NotificationWebhookConfig{
+		URL:           srv.URL,
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		RetryBackoff:  10 * time.Millisecond,
+		Logger:        log.New(os.Stdout, "---> webhook ", log.LstdFlags),
+		Debug:         true,
+	})
+	if err != nil {
+		t.Fatalf("Error creating webhook: %s", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		wh.Handle(consultant.Notification{
+			ID:     "notification",
+			Source: consultant.NotificationSourceTest,
+			Event:  consultant.NotificationEventTestPush,
+			Data:   i,
+		})
+	}
+
+	if err := wh.Close(); err != nil {
+		t.Fatalf("Error closing webhook: %s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 3 {
+		t.Logf("Expected 3 notifications, saw %d", len(received))
+		t.Fail()
+	}
+	if v := atomic.LoadUint64(&attempts); v != 4 {
+		t.Logf("Expected 4 requests for 2 batches, saw %d", v)
+		t.Fail()
+	}
+}
+
+func TestNotificationWebhook_MaxPending(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []consultant.SerializedNotification
+
+		requested = make(chan struct{}, 1)
+		release   = make(chan struct{})
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// hold requests until released, simulating an outage
+		select {
+		case requested <- struct{}{}:
+		default:
+		}
+		<-release
+		var batch []consultant.SerializedNotification
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Logf("Error decoding batch: %s", err)
+			t.Fail()
+		}
+		mu.Lock()
+		received = append(received, batch...)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	wh, err := consultant.NewNotificationWebhook(&consultant.NotificationWebhookConfig{
+		URL:           srv.URL,
+		BatchSize:     2,
+		MaxPending:    3,
+		FlushInterval: time.Hour,
+		MaxRetries:    -1,
+		Logger:        log.New(os.Stdout, "---> webhook ", log.LstdFlags),
+		Debug:         true,
+	})
+	if err != nil {
+		t.Fatalf("Error creating webhook: %s", err)
+	}
+
+	handle := func(i int) {
+		wh.Handle(consultant.Notification{
+			ID:     "notification",
+			Source: consultant.NotificationSourceTest,
+			Event:  consultant.NotificationEventTestPush,
+			Data:   i,
+		})
+	}
+
+	// first batch is taken by the flush routine, which is then held by the server
+	handle(0)
+	handle(1)
+	select {
+	case <-requested:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected first batch to be sent within 5 seconds")
+	}
+
+	// only the newest MaxPending of the remainder are kept
+	for i := 2; i < 12; i++ {
+		handle(i)
+	}
+	if v := wh.Dropped(); v != 7 {
+		t.Logf("Expected 7 dropped, saw %d", v)
+		t.Fail()
+	}
+
+	close(release)
+	if err := wh.Close(); err != nil {
+		t.Fatalf("Error closing webhook: %s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []int{0, 1, 9, 10, 11}
+	if len(received) != len(expected) {
+		t.Fatalf("Expected %d notifications, saw %d", len(expected), len(received))
+	}
+	for i, v := range expected {
+		if d, ok := received[i].Data.(float64); !ok || int(d) != v {
+			t.Logf("Expected notification %d to have data %d, saw %v", i, v, received[i].Data)
+			t.Fail()
+		}
+	}
+}
+
+func TestNotificationFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.jsonl")
+
+	nf, err := consultant.NewNotificationFile(&consultant.NotificationFileConfig{
+		Path:       path,
+		MaxBytes:   256,
+		MaxBackups: 2,
+	})
+	if err != nil {
+		t.Fatalf("Error creating file sink: %s", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		nf.Handle(consultant.Notification{
+			ID:     "notification",
+			Source: consultant.NotificationSourceTest,
+			Event:  consultant.NotificationEventTestPush,
+			Data:   i,
+		})
+	}
+	if err := nf.Close(); err != nil {
+		t.Fatalf("Error closing file sink: %s", err)
+	}
+
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		f, err := os.Open(p)
+		if err != nil {
+			t.Logf("Expected %q to exist: %s", p, err)
+			t.Fail()
+			continue
+		}
+		fi, _ := f.Stat()
+		if fi.Size() > 256 {
+			t.Logf("Expected %q to be no larger than 256 bytes, saw %d", p, fi.Size())
+			t.Fail()
+		}
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			var sn consultant.SerializedNotification
+			if err := json.Unmarshal(sc.Bytes(), &sn); err != nil {
+				t.Logf("Error decoding line in %q: %s", p, err)
+				t.Fail()
+			}
+		}
+		_ = f.Close()
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Log("Expected no more than 2 backups")
+		t.Fail()
+	}
+}
+
+func TestNotificationFile_RotateFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.jsonl")
+
+	// a directory in the first backup slot causes every rotation to fail
+	if err := os.Mkdir(path+".1", 0700); err != nil {
+		t.Fatalf("Error creating blocking directory: %s", err)
+	}
+
+	nf, err := consultant.NewNotificationFile(&consultant.NotificationFileConfig{
+		Path:       path,
+		MaxBytes:   256,
+		MaxBackups: 1,
+	})
+	if err != nil {
+		t.Fatalf("Error creating file sink: %s", err)
+	}
+
+	const count = 10
+	for i := 0; i < count; i++ {
+		nf.Handle(consultant.Notification{
+			ID:     "notification",
+			Source: consultant.NotificationSourceTest,
+			Event:  consultant.NotificationEventTestPush,
+			Data:   i,
+		})
+	}
+	if err := nf.Close(); err != nil {
+		t.Fatalf("Error closing file sink: %s", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Error opening %q: %s", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	lines := 0
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines++
+	}
+	if lines != count {
+		t.Logf("Expected all %d notifications to be written despite failed rotations, saw %d", count, lines)
+		t.Fail()
+	}
+}
@@ -0,0 +1,847 @@
+package consultant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ServiceSupervisorState uint8
+
+const (
+	// 0x30 - 0x3f
+	ServiceSupervisorStateStopped    ServiceSupervisorState = 0x30
+	ServiceSupervisorStateRunning    ServiceSupervisorState = 0x31
+	ServiceSupervisorStateShutdowned ServiceSupervisorState = 0x32
+)
+
+func (s ServiceSupervisorState) String() string {
+	switch s {
+	case ServiceSupervisorStateStopped:
+		return "stopped"
+	case ServiceSupervisorStateRunning:
+		return "running"
+	case ServiceSupervisorStateShutdowned:
+		return "shutdowned"
+
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ServiceSupervisorUpdate is the value of .Data in all Notification pushes from a ServiceSupervisor
+type ServiceSupervisorUpdate struct {
+	// ServiceID and ServiceName will be empty for notifications that do not pertain to a specific service
+	ServiceID     string                 `json:"service_id"`
+	ServiceName   string                 `json:"service_name"`
+	State         ServiceSupervisorState `json:"state"`
+	LastRefreshed time.Time              `json:"last_refreshed"`
+	Error         error                  `json:"error"`
+}
+
+// ServiceSupervisorConfig describes the basis for a new ServiceSupervisor instance
+type ServiceSupervisorConfig struct {
+	// RefreshInterval [optional]
+	//
+	// Optionally specify a refresh interval.  Defaults to value of ServiceDefaultRefreshInterval.  A refresh is also
+	// performed any time the shared watch sees a change to the services registered on the local node.
+	RefreshInterval api.ReadableDuration
+
+	// QueryOptions [optional]
+	//
+	// Options to use whenever making a read api query.  This will be shallow copied per internal request made.
+	QueryOptions *api.QueryOptions
+
+	// RequestTTL [optional]
+	//
+	// Optionally specify a TTL to pass to internal API requests.  Defaults to 2 seconds
+	RequestTTL time.Duration
+
+	// DrainGracePeriod [optional]
+	//
+	// Maximum amount of time Drain will wait between placing services into maintenance mode and deregistering them.
+	// Defaults to value of ServiceDefaultDrainGracePeriod.
+	DrainGracePeriod time.Duration
+
+	// DrainWaitForHealth [optional]
+	//
+	// If true, Drain will end its wait as soon as none of the drained services are seen as passing by the health
+	// endpoint, rather than always waiting for the full DrainGracePeriod.
+	DrainWaitForHealth bool
+
+	// Logger [optional]
+	//
+	// Optionally specify a logger.  No logging will take place if one is not provided
+	Logger Logger
+
+	// Debug [optional]
+	//
+	// If true, will enable debug-level logging if a logger is provided
+	Debug bool
+
+	// StructuredLogger [optional]
+	//
+	// Optionally specify a levelled, structured logger to use in place of Logger.  If defined, Logger and Debug are
+	// ignored.
+	StructuredLogger StructuredLogger
+
+	// Client [optional]
+	//
+	// Optionally provide a Consul client instance to use.  If one is not defined, a new one will be created with
+	// default configuration values.
+	Client *api.Client
+
+	// NotificationBus [optional]
+	//
+	// If defined, the supervisor will register itself with this bus on construction under a random producer identity.  Notifications
+	// for individual services are only available from ServiceNotifier.
+	NotificationBus *NotificationBus
+
+	// Metrics [optional]
+	//
+	// If defined, re-registrations and dropped notifications will be recorded here
+	Metrics MetricsRecorder
+
+	// TracerProvider [optional]
+	//
+	// If defined, calls made to the Consul agent to register and deregister services will be wrapped in spans from this
+	// provider
+	TracerProvider trace.TracerProvider
+}
+
+// supervisedService is a single registration owned by a ServiceSupervisor
+type supervisedService struct {
+	*notifierBase
+
+	// reg is the full definition of the service, used both to register and re-register it, as well as the desired
+	// state checked for drift during reconciliation
+	reg *api.AgentServiceRegistration
+
+	// deregistering is set while the service is being deregistered, so that reconciliation neither re-registers it nor
+	// leaves it registered should it have done so concurrently
+	deregistering bool
+}
+
+// ServiceSupervisor
+//
+// This type owns any number of service registrations on the local agent.  Where each ManagedService runs its own
+// maintenance loop and watch plan, a ServiceSupervisor shares a single watch of the services registered to the local
+// node and reconciles all of its registrations in one pass.
+type ServiceSupervisor struct {
+	*notifierBase
+	mu sync.RWMutex
+
+	state    ServiceSupervisorState
+	services map[string]*supervisedService
+
+	refreshInterval time.Duration
+	localRefreshed  time.Time
+	forceRefresh    chan chan error
+
+	drainGrace         time.Duration
+	drainWaitForHealth bool
+
+	client *api.Client
+	qo     *api.QueryOptions
+	rttl   time.Duration
+	tracer trace.Tracer
+
+	stop chan chan error
+}
+
+// NewServiceSupervisor creates a new ServiceSupervisor instance.  It will not begin reconciling until Run is called.
+func NewServiceSupervisor(cfg *ServiceSupervisorConfig) (*ServiceSupervisor, error) {
+	var (
+		err error
+
+		ss = new(ServiceSupervisor)
+	)
+
+	if cfg == nil {
+		cfg = new(ServiceSupervisorConfig)
+	}
+
+	ss.notifierBase = newNotifierBase(buildStructuredLogger(
+		cfg.StructuredLogger,
+		cfg.Logger,
+		cfg.Debug,
+		LogKeyComponent, LogComponentServiceSupervisor,
+	))
+	ss.metrics = cfg.Metrics
+	ss.tracer = newTracer(cfg.TracerProvider)
+	ss.state = ServiceSupervisorStateStopped
+	ss.services = make(map[string]*supervisedService)
+
+	if cfg.Client != nil {
+		ss.client = cfg.Client
+	} else if ss.client, err = api.NewClient(api.DefaultConfig()); err != nil {
+		return nil, fmt.Errorf("error creating client with default config: %s", err)
+	}
+
+	if cfg.QueryOptions != nil {
+		ss.qo = new(api.QueryOptions)
+		*ss.qo = *cfg.QueryOptions
+	}
+
+	if cfg.RefreshInterval != 0 {
+		ss.refreshInterval = cfg.RefreshInterval.Duration()
+	} else {
+		ss.refreshInterval = time.Duration(ServiceDefaultRefreshInterval)
+	}
+
+	if cfg.RequestTTL > 0 {
+		ss.rttl = cfg.RequestTTL
+	} else {
+		ss.rttl = defaultInternalRequestTTL
+	}
+
+	if cfg.DrainGracePeriod > 0 {
+		ss.drainGrace = cfg.DrainGracePeriod
+	} else {
+		ss.drainGrace = ServiceDefaultDrainGracePeriod
+	}
+	ss.drainWaitForHealth = cfg.DrainWaitForHealth
+
+	ss.forceRefresh = make(chan chan error)
+	ss.stop = make(chan chan error)
+
+	if cfg.NotificationBus != nil {
+		cfg.NotificationBus.join("", ss)
+	}
+
+	return ss, nil
+}
+
+// State returns the current state of this supervisor
+func (ss *ServiceSupervisor) State() ServiceSupervisorState {
+	ss.mu.RLock()
+	s := ss.state
+	ss.mu.RUnlock()
+	return s
+}
+
+// Running returns true if state is "running"
+func (ss *ServiceSupervisor) Running() bool {
+	return ss.State() == ServiceSupervisorStateRunning
+}
+
+// Shutdowned returns true if state is "shutdowned"
+func (ss *ServiceSupervisor) Shutdowned() bool {
+	return ss.State() == ServiceSupervisorStateShutdowned
+}
+
+// LastRefreshed is the last time a reconciliation pass was completed
+func (ss *ServiceSupervisor) LastRefreshed() time.Time {
+	ss.mu.RLock()
+	lr := ss.localRefreshed
+	ss.mu.RUnlock()
+	return lr
+}
+
+// ServiceIDs returns the sorted list of service ids currently owned by this supervisor
+func (ss *ServiceSupervisor) ServiceIDs() []string {
+	ss.mu.RLock()
+	ids := make([]string, 0, len(ss.services))
+	for id := range ss.services {
+		ids = append(ids, id)
+	}
+	ss.mu.RUnlock()
+	sort.Strings(ids)
+	return ids
+}
+
+// ServiceNotifier returns the Notifier that receives only those notifications pertaining to the provided service id.
+// All notifications are additionally pushed to recipients attached to the supervisor itself.
+func (ss *ServiceSupervisor) ServiceNotifier(serviceID string) (Notifier, bool) {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	if svc, ok := ss.services[serviceID]; ok {
+		return svc.notifierBase, true
+	}
+	return nil, false
+}
+
+// Run starts the shared watch and reconciliation loop
+func (ss *ServiceSupervisor) Run() error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.state == ServiceSupervisorStateShutdowned {
+		ss.log.Info("Run() called but we're shutdowned")
+		return errors.New("service supervisor is shutdowned")
+	}
+
+	if ss.state == ServiceSupervisorStateRunning {
+		ss.log.Debug("Run() called but we're already running")
+		return nil
+	}
+
+	ss.setState(ServiceSupervisorStateRunning)
+
+	go ss.maintain()
+
+	return nil
+}
+
+// Shutdown stops the reconciliation loop and deregisters all owned services.  Once shutdown, the supervisor is
+// considered defunct.
+func (ss *ServiceSupervisor) Shutdown() error {
+	ss.mu.Lock()
+	if ss.state == ServiceSupervisorStateShutdowned {
+		ss.mu.Unlock()
+		ss.log.Debug("Shutdown() called but we're already shutdowned")
+		return nil
+	}
+
+	requiresStop := ss.state == ServiceSupervisorStateRunning
+
+	ss.setState(ServiceSupervisorStateShutdowned)
+
+	ss.mu.Unlock()
+
+	if requiresStop {
+		drop := make(chan error, 1)
+		ss.stop <- drop
+		<-drop
+		close(drop)
+	}
+
+	err := ss.Deregister(ss.ServiceIDs()...)
+
+	ss.DetachAllNotificationRecipients(true)
+
+	ss.mu.Lock()
+	close(ss.forceRefresh)
+	close(ss.stop)
+	ss.mu.Unlock()
+
+	return err
+}
+
+// Register registers each of the provided services with the local agent, taking ownership of them.  Any service
+// without an ID will have one generated from its name and the local hostname, so registering the same definition again
+// updates the existing service rather than adding another.  A registration attempt is made for every service, with any
+// errors seen returned together.
+func (ss *ServiceSupervisor) Register(regs ...*api.AgentServiceRegistration) error {
+	if ss.Shutdowned() {
+		return errors.New("service supervisor is shutdowned")
+	}
+
+	var (
+		errs []error
+		svcs []*supervisedService
+		rs   []*api.AgentServiceRegistration
+
+		localHostname, _ = os.Hostname()
+	)
+
+	ss.mu.Lock()
+	for _, reg := range regs {
+		if reg == nil {
+			continue
+		}
+
+		// copy registration so that it cannot be modified externally
+		r := cloneServiceRegistration(reg)
+		if r.ID == "" {
+			r.ID = defaultSimpleServiceID(r.Name, localHostname, false)
+		}
+
+		// a service being deregistered is replaced, so that its deregistration does not relinquish the new one
+		svc, ok := ss.services[r.ID]
+		if !ok || svc.deregistering {
+			svc = &supervisedService{notifierBase: newNotifierBase(ss.log.With(LogKeyServiceID, r.ID))}
+			svc.metrics = ss.metrics
+			ss.services[r.ID] = svc
+		}
+		svc.reg = r
+		svcs = append(svcs, svc)
+		rs = append(rs, r)
+	}
+	ss.mu.Unlock()
+
+	// agent calls are made without holding lock, using the registrations captured above
+	for i, svc := range svcs {
+		ctx, cancel := context.WithTimeout(context.Background(), ss.rttl)
+		err := ss.registerService(ctx, rs[i])
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error registering service %q: %w", rs[i].ID, err))
+		}
+
+		ss.mu.RLock()
+		ss.pushServiceNotification(svc, NotificationEventServiceSupervisorServiceRegistered, err)
+		ss.mu.RUnlock()
+	}
+
+	return errors.Join(errs...)
+}
+
+// Deregister removes each of the provided services from the local agent and relinquishes ownership of them
+func (ss *ServiceSupervisor) Deregister(serviceIDs ...string) error {
+	return ss.deregister(context.Background(), serviceIDs...)
+}
+
+func (ss *ServiceSupervisor) deregister(ctx context.Context, serviceIDs ...string) error {
+	var errs []error
+
+	for _, id := range serviceIDs {
+		ss.mu.Lock()
+		svc, ok := ss.services[id]
+		if ok {
+			svc.deregistering = true
+		}
+		ss.mu.Unlock()
+		if !ok {
+			errs = append(errs, fmt.Errorf("service %q is not owned by this supervisor", id))
+			continue
+		}
+
+		rctx, cancel := context.WithTimeout(ctx, ss.rttl)
+		rctx, span := startSpan(rctx, ss.tracer, TraceSpanAgentServiceDeregister, TraceAttributeServiceID.String(id))
+		err := ss.client.Agent().ServiceDeregisterOpts(id, ss.qo.WithContext(rctx))
+		endSpan(span, err)
+		cancel()
+
+		ss.mu.Lock()
+		if err != nil && !IsNotFoundError(err) {
+			errs = append(errs, fmt.Errorf("error deregistering service %q: %w", id, err))
+			svc.deregistering = false
+		} else {
+			err = nil
+			// the service may have been re-registered in the meantime
+			if ss.services[id] == svc {
+				delete(ss.services, id)
+			}
+		}
+		ss.pushServiceNotification(svc, NotificationEventServiceSupervisorServiceDeregistered, err)
+		ss.mu.Unlock()
+
+		if err == nil {
+			go svc.DetachAllNotificationRecipients(true)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Drain places each of the provided services into maintenance mode, waits for them to drain, then deregisters them.
+// If no service ids are provided, all owned services are drained.
+func (ss *ServiceSupervisor) Drain(ctx context.Context, serviceIDs ...string) error {
+	var (
+		errs []error
+		ids  []string
+		svcs []*supervisedService
+	)
+
+	if len(serviceIDs) == 0 {
+		serviceIDs = ss.ServiceIDs()
+	}
+
+	ss.mu.RLock()
+	for _, id := range serviceIDs {
+		if svc, ok := ss.services[id]; ok {
+			ids = append(ids, id)
+			svcs = append(svcs, svc)
+		}
+	}
+	ss.mu.RUnlock()
+
+	// agent calls are made without holding lock
+	for i, svc := range svcs {
+		id := ids[i]
+		rctx, cancel := context.WithTimeout(ctx, ss.rttl)
+		err := ss.client.Agent().EnableServiceMaintenanceOpts(id, ServiceDrainReason, ss.qo.WithContext(rctx))
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error placing service %q into maintenance mode: %w", id, err))
+		}
+		ss.mu.RLock()
+		ss.pushServiceNotification(svc, NotificationEventServiceSupervisorServiceDrainStarted, err)
+		ss.mu.RUnlock()
+	}
+
+	ss.waitForDrain(ctx, serviceIDs)
+
+	ss.mu.RLock()
+	for _, svc := range svcs {
+		ss.pushServiceNotification(svc, NotificationEventServiceSupervisorServiceDrainFinished, nil)
+	}
+	ss.mu.RUnlock()
+
+	// deregistration must proceed even if ctx expired while waiting for drain
+	if err := ss.deregister(context.WithoutCancel(ctx), serviceIDs...); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// ForceRefresh attempts an immediate reconciliation pass, blocking until attempt has been completed.
+func (ss *ServiceSupervisor) ForceRefresh() error {
+	if !ss.Running() {
+		return errors.New("service supervisor is not running")
+	}
+	ss.log.Debug("Force refresh requested")
+	ch := make(chan error, 1)
+	defer close(ch)
+	ss.forceRefresh <- ch
+	return <-ch
+}
+
+// buildUpdate constructs a notification update type.  svc may be nil.
+//
+// caller must hold lock
+func (ss *ServiceSupervisor) buildUpdate(svc *supervisedService, err error) ServiceSupervisorUpdate {
+	up := ServiceSupervisorUpdate{
+		State:         ss.state,
+		LastRefreshed: ss.localRefreshed,
+		Error:         err,
+	}
+	if svc != nil {
+		up.ServiceID = svc.reg.ID
+		up.ServiceName = svc.reg.Name
+	}
+	return up
+}
+
+// pushServiceNotification pushes a notification pertaining to a specific service to both the service's own recipients
+// and those attached to the supervisor
+//
+// caller must hold lock
+func (ss *ServiceSupervisor) pushServiceNotification(svc *supervisedService, ev NotificationEvent, err error) {
+	up := ss.buildUpdate(svc, err)
+	svc.sendNotification(NotificationSourceServiceSupervisor, ev, up)
+	ss.sendNotification(NotificationSourceServiceSupervisor, ev, up)
+}
+
+// setState updates the internal state value and pushes a notification of change
+//
+// caller must hold full lock
+func (ss *ServiceSupervisor) setState(state ServiceSupervisorState) {
+	var ev NotificationEvent
+
+	if ss.state == state {
+		return
+	}
+
+	switch state {
+	case ServiceSupervisorStateRunning:
+		ev = NotificationEventServiceSupervisorRunning
+	case ServiceSupervisorStateStopped:
+		ev = NotificationEventServiceSupervisorStopped
+	case ServiceSupervisorStateShutdowned:
+		ev = NotificationEventServiceSupervisorShutdowned
+
+	default:
+		panic(fmt.Sprintf("unknown state %d (%[1]s) seen", state))
+	}
+
+	ss.state = state
+
+	ss.sendNotification(NotificationSourceServiceSupervisor, ev, ss.buildUpdate(nil, nil))
+}
+
+// registerService pushes the provided full service definition to the local agent.  reg must not be modified once owned
+// by a supervised service, and so may be used without holding lock.
+func (ss *ServiceSupervisor) registerService(ctx context.Context, reg *api.AgentServiceRegistration) error {
+	ss.log.Debug("Registering service with node", LogKeyServiceID, reg.ID)
+	ctx, span := startSpan(ctx, ss.tracer, TraceSpanAgentServiceRegister, TraceAttributeServiceID.String(reg.ID))
+	err := ss.client.Agent().ServiceRegisterOpts(reg, api.ServiceRegisterOpts{}.WithContext(ctx))
+	endSpan(span, err)
+	if err != nil {
+		ss.log.Error("Error registering service", LogKeyServiceID, reg.ID, LogKeyError, err)
+	}
+	return err
+}
+
+// reconcile fetches all services currently registered to the local agent in a single request, re-registering any owned
+// service that is either missing or has drifted from its definition.  Which services to re-register is determined
+// under lock, with all agent calls made after it has been released.
+//
+// caller must not hold lock
+func (ss *ServiceSupervisor) reconcile(ctx context.Context) error {
+	type action struct {
+		id  string
+		svc *supervisedService
+		reg *api.AgentServiceRegistration
+		ev  NotificationEvent
+		err error
+	}
+
+	var (
+		current map[string]*api.AgentService
+		actions []*action
+		stale   []string
+		errs    []error
+		err     error
+	)
+
+	if current, err = ss.client.Agent().ServicesWithFilterOpts("", ss.qo.WithContext(ctx)); err != nil {
+		ss.log.Error("Error fetching services from agent", LogKeyError, err)
+		ss.mu.RLock()
+		ss.sendNotification(NotificationSourceServiceSupervisor, NotificationEventServiceSupervisorRefreshed, ss.buildUpdate(nil, err))
+		ss.mu.RUnlock()
+		return err
+	}
+
+	ss.mu.RLock()
+	ss.log.Debug("Reconciling services", "count", len(ss.services))
+	for id, svc := range ss.services {
+		var ev NotificationEvent
+
+		if svc.deregistering {
+			continue
+		} else if as, ok := current[id]; !ok {
+			ss.log.Warn("Service is missing, re-registering", LogKeyServiceID, id)
+			ev = NotificationEventServiceSupervisorServiceMissing
+		} else if supervisedServiceDrifted(svc.reg, as) {
+			ss.log.Warn("Service has drifted from its definition, re-registering", LogKeyServiceID, id)
+			ev = NotificationEventServiceSupervisorServiceDrift
+		} else {
+			continue
+		}
+
+		actions = append(actions, &action{id: id, svc: svc, reg: svc.reg, ev: ev})
+	}
+	ss.mu.RUnlock()
+
+	for _, a := range actions {
+		if a.err = ss.registerService(ctx, a.reg); a.err != nil {
+			errs = append(errs, fmt.Errorf("error re-registering service %q: %w", a.id, a.err))
+		}
+		if ss.metrics != nil {
+			ss.metrics.ServiceReRegistered(a.id, a.err)
+		}
+	}
+
+	ss.mu.Lock()
+	for _, a := range actions {
+		// a service deregistered while being re-registered must be removed again, lest it be left behind unowned.  one
+		// that was otherwise replaced in the meantime will be corrected by the next pass.
+		if curr, ok := ss.services[a.id]; a.err == nil && (!ok || curr.deregistering) {
+			stale = append(stale, a.id)
+		}
+		ss.pushServiceNotification(a.svc, a.ev, a.err)
+	}
+	ss.mu.Unlock()
+
+	for _, id := range stale {
+		ss.log.Warn("Service was deregistered while being re-registered, deregistering again", LogKeyServiceID, id)
+		rctx, span := startSpan(ctx, ss.tracer, TraceSpanAgentServiceDeregister, TraceAttributeServiceID.String(id))
+		derr := ss.client.Agent().ServiceDeregisterOpts(id, ss.qo.WithContext(rctx))
+		endSpan(span, derr)
+		if derr != nil && !IsNotFoundError(derr) {
+			errs = append(errs, fmt.Errorf("error deregistering relinquished service %q: %w", id, derr))
+		}
+	}
+
+	err = errors.Join(errs...)
+
+	ss.mu.Lock()
+	if err == nil {
+		ss.localRefreshed = time.Now()
+	}
+	ss.sendNotification(NotificationSourceServiceSupervisor, NotificationEventServiceSupervisorRefreshed, ss.buildUpdate(nil, err))
+	ss.mu.Unlock()
+
+	return err
+}
+
+// waitForDrain blocks until the drain grace period has elapsed, the provided context is done, or none of the provided
+// services are seen as passing if configured to wait for health.
+func (ss *ServiceSupervisor) waitForDrain(ctx context.Context, serviceIDs []string) {
+	var (
+		poll *time.Ticker
+		pc   <-chan time.Time
+
+		grace = time.NewTimer(ss.drainGrace)
+	)
+
+	defer grace.Stop()
+
+	if ss.drainWaitForHealth {
+		poll = time.NewTicker(serviceDrainPollInterval)
+		defer poll.Stop()
+		pc = poll.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-grace.C:
+			return
+		case <-pc:
+			if !ss.anyPassing(ctx, serviceIDs) {
+				ss.log.Debug("No drained services are seen as passing")
+				return
+			}
+		}
+	}
+}
+
+// anyPassing returns true if any of the provided services are still seen as passing.  Errors are considered passing.
+func (ss *ServiceSupervisor) anyPassing(ctx context.Context, serviceIDs []string) bool {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	names := make(map[string][]string)
+	for _, id := range serviceIDs {
+		if svc, ok := ss.services[id]; ok {
+			names[svc.reg.Name] = append(names[svc.reg.Name], id)
+		}
+	}
+
+	for name, ids := range names {
+		rctx, cancel := context.WithTimeout(ctx, ss.rttl)
+		entries, _, err := ss.client.Health().ServiceMultipleTags(name, nil, true, ss.qo.WithContext(rctx))
+		cancel()
+		if err != nil {
+			ss.log.Error("Error querying service health", "service_name", name, LogKeyError, err)
+			return true
+		}
+		for _, id := range ids {
+			if _, ok := SpecificServiceEntry(id, entries); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// watchNode performs blocking queries against the services registered to the local node, pushing to up whenever the
+// index changes.  It returns once ctx is done.
+func (ss *ServiceSupervisor) watchNode(ctx context.Context, up chan<- struct{}) {
+	var (
+		node  string
+		last  uint64
+		qm    *api.QueryMeta
+		err   error
+		retry = time.NewTimer(0)
+	)
+
+	defer retry.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-retry.C:
+		}
+
+		if node == "" {
+			if node, err = ss.client.Agent().NodeName(); err != nil {
+				ss.log.Error("Error determining local node name", LogKeyError, err)
+				retry.Reset(time.Second)
+				continue
+			}
+		}
+
+		qo := ss.qo.WithContext(ctx)
+		qo.WaitIndex = last
+		if _, qm, err = ss.client.Catalog().NodeServiceList(node, qo); err != nil {
+			if ctx.Err() == nil {
+				ss.log.Error("Error watching services on node", "node", node, LogKeyError, err)
+			}
+			retry.Reset(time.Second)
+			continue
+		}
+
+		if qm.LastIndex != last {
+			if last != 0 {
+				select {
+				case up <- struct{}{}:
+				default:
+				}
+			}
+			last = qm.LastIndex
+		}
+
+		retry.Reset(0)
+	}
+}
+
+func (ss *ServiceSupervisor) maintain() {
+	var (
+		update       = make(chan struct{}, 1)
+		refreshTimer = time.NewTimer(ss.refreshInterval)
+
+		ctx, cancel = context.WithCancel(context.Background())
+		wg          = new(sync.WaitGroup)
+	)
+
+	wg.Add(1)
+	go func() {
+		ss.watchNode(ctx, update)
+		wg.Done()
+	}()
+
+	refresh := func() error {
+		rctx, rcancel := context.WithTimeout(ctx, ss.rttl)
+		defer rcancel()
+		return ss.reconcile(rctx)
+	}
+
+	resetTimer := func() {
+		if !refreshTimer.Stop() && len(refreshTimer.C) > 0 {
+			<-refreshTimer.C
+		}
+		refreshTimer.Reset(ss.refreshInterval)
+	}
+
+	ss.log.Debug("Entering maintenance loop")
+
+	for {
+		select {
+		case ch := <-ss.forceRefresh:
+			ss.log.Debug("Force refresh hit")
+			ch <- refresh()
+			resetTimer()
+
+		case <-update:
+			ss.log.Debug("Node watch has received update")
+			_ = refresh()
+			resetTimer()
+
+		case tick := <-refreshTimer.C:
+			ss.log.Debug("Refresh interval reached", "tick", tick)
+			_ = refresh()
+			refreshTimer.Reset(ss.refreshInterval)
+
+		case drop := <-ss.stop:
+			ss.log.Info("Stop hit")
+			cancel()
+			wg.Wait()
+			refreshTimer.Stop()
+			drop <- nil
+			return
+		}
+	}
+}
+
+// supervisedServiceDrifted returns true if the upstream service differs from its definition in any field that is
+// checked for drift.
+func supervisedServiceDrifted(reg *api.AgentServiceRegistration, svc *api.AgentService) bool {
+	if reg.Name != svc.Service || reg.Port != svc.Port || !strSlicesEqual(reg.Tags, svc.Tags) {
+		return true
+	}
+	if reg.Address != "" && reg.Address != svc.Address {
+		return true
+	}
+	if reg.Meta != nil && !strMapsEqual(reg.Meta, svc.Meta) {
+		return true
+	}
+	if reg.Weights != nil && *reg.Weights != svc.Weights {
+		return true
+	}
+	return false
+}
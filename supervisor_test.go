@@ -0,0 +1,185 @@
+package consultant_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/myENA/consultant/v2"
+)
+
+const (
+	supervisedServiceName  = "supervised"
+	supervisedServiceCount = 3
+)
+
+func TestServiceSupervisor(t *testing.T) {
+	server, client := makeTestServerAndClient(t, nil)
+	defer stopTestServer(server)
+	server.WaitForSerfCheck(t)
+
+	cfg := new(consultant.ServiceSupervisorConfig)
+	cfg.Client = client.Client
+	cfg.Logger = log.New(os.Stdout, "---> service supervisor ", log.LstdFlags)
+	cfg.Debug = true
+	cfg.DrainGracePeriod = time.Second
+
+	ss, err := consultant.NewServiceSupervisor(cfg)
+	if err != nil {
+		t.Fatalf("Error creating service supervisor: %s", err)
+	}
+
+	addr := getTestLocalAddr(t)
+
+	regs := make([]*api.AgentServiceRegistration, supervisedServiceCount)
+	for i := range regs {
+		regs[i] = &api.AgentServiceRegistration{
+			ID:      fmt.Sprintf("%s-%d", supervisedServiceName, i),
+			Name:    supervisedServiceName,
+			Address: addr,
+			Port:    managedServicePort + i,
+			Tags:    []string{"supervised"},
+		}
+	}
+
+	if err := ss.Register(regs...); err != nil {
+		t.Fatalf("Error registering services: %s", err)
+	}
+	if err := ss.Run(); err != nil {
+		t.Fatalf("Error running service supervisor: %s", err)
+	}
+
+	registered := func(t *testing.T, id string) bool {
+		svcs, err := client.Agent().Services()
+		if err != nil {
+			t.Fatalf("Error fetching services: %s", err)
+		}
+		_, ok := svcs[id]
+		return ok
+	}
+
+	t.Run("registered", func(t *testing.T) {
+		for _, reg := range regs {
+			if !registered(t, reg.ID) {
+				t.Logf("Expected service %q to be registered", reg.ID)
+				t.Fail()
+			}
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		var missing uint64
+
+		n, ok := ss.ServiceNotifier(regs[0].ID)
+		if !ok {
+			t.Fatalf("Expected notifier for service %q", regs[0].ID)
+		}
+		n.AttachNotificationHandler("", func(n consultant.Notification) {
+			if n.Event == consultant.NotificationEventServiceSupervisorServiceMissing {
+				atomic.StoreUint64(&missing, 1)
+			}
+		})
+
+		if err := client.Agent().ServiceDeregister(regs[0].ID); err != nil {
+			t.Fatalf("Error deregistering service: %s", err)
+		}
+		if err := ss.ForceRefresh(); err != nil {
+			t.Fatalf("Error refreshing: %s", err)
+		}
+		if !registered(t, regs[0].ID) {
+			t.Logf("Expected service %q to be re-registered", regs[0].ID)
+			t.Fail()
+		}
+
+		for i := 0; i < 10 && atomic.LoadUint64(&missing) == 0; i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if atomic.LoadUint64(&missing) == 0 {
+			t.Log("Expected missing notification")
+			t.Fail()
+		}
+	})
+
+	t.Run("drift", func(t *testing.T) {
+		reg := *regs[1]
+		reg.Tags = []string{"not-supervised"}
+		if err := client.Agent().ServiceRegister(&reg); err != nil {
+			t.Fatalf("Error altering service: %s", err)
+		}
+		if err := ss.ForceRefresh(); err != nil {
+			t.Fatalf("Error refreshing: %s", err)
+		}
+		svc, _, err := client.Agent().Service(regs[1].ID, nil)
+		if err != nil {
+			t.Fatalf("Error fetching service: %s", err)
+		}
+		if len(svc.Tags) != 1 || svc.Tags[0] != "supervised" {
+			t.Logf("Expected drift to be corrected, saw tags %v", svc.Tags)
+			t.Fail()
+		}
+	})
+
+	t.Run("generated-id", func(t *testing.T) {
+		before := len(ss.ServiceIDs())
+		reg := &api.AgentServiceRegistration{Name: supervisedServiceName + "-unnamed", Port: 1234}
+
+		// registering the same definition twice must not create a second service
+		for i := 0; i < 2; i++ {
+			if err := ss.Register(reg); err != nil {
+				t.Fatalf("Error registering service without id: %s", err)
+			}
+		}
+
+		ids := ss.ServiceIDs()
+		if len(ids) != before+1 {
+			t.Fatalf("Expected %d supervised services, saw %v", before+1, ids)
+		}
+
+		var id string
+		for _, sid := range ids {
+			if strings.HasPrefix(sid, reg.Name+"-") {
+				id = sid
+			}
+		}
+		if id == "" {
+			t.Fatalf("Expected a service id generated from %q, saw %v", reg.Name, ids)
+		}
+		if err := ss.Deregister(id); err != nil {
+			t.Fatalf("Error deregistering service %q: %s", id, err)
+		}
+	})
+
+	t.Run("drain", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := ss.Drain(ctx, regs[2].ID); err != nil {
+			t.Fatalf("Error draining service: %s", err)
+		}
+		if registered(t, regs[2].ID) {
+			t.Logf("Expected service %q to be deregistered after drain", regs[2].ID)
+			t.Fail()
+		}
+		if len(ss.ServiceIDs()) != supervisedServiceCount-1 {
+			t.Logf("Expected %d supervised services, saw %v", supervisedServiceCount-1, ss.ServiceIDs())
+			t.Fail()
+		}
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		if err := ss.Shutdown(); err != nil {
+			t.Fatalf("Error shutting down: %s", err)
+		}
+		for _, reg := range regs {
+			if registered(t, reg.ID) {
+				t.Logf("Expected service %q to be deregistered on shutdown", reg.ID)
+				t.Fail()
+			}
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package consultant
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	// TracerName is the instrumentation name spans created by managed types are recorded under
+	TracerName = "github.com/myENA/consultant/v2"
+)
+
+// Attribute keys set on spans created by managed types
+const (
+	TraceAttributeSessionID   = attribute.Key("consultant.session.id")
+	TraceAttributeSessionName = attribute.Key("consultant.session.name")
+	TraceAttributeKVKey       = attribute.Key("consultant.kv.key")
+	TraceAttributeCandidateID = attribute.Key("consultant.candidate.id")
+	TraceAttributeServiceID   = attribute.Key("consultant.service.id")
+)
+
+// Names of spans created by managed types around their calls to Consul
+const (
+	TraceSpanSessionCreate           = "consul.session.create"
+	TraceSpanSessionRenew            = "consul.session.renew"
+	TraceSpanSessionDestroy          = "consul.session.destroy"
+	TraceSpanKVAcquire               = "consul.kv.acquire"
+	TraceSpanKVDelete                = "consul.kv.delete"
+	TraceSpanAgentServiceRegister    = "consul.agent.service.register"
+	TraceSpanAgentServiceDeregister  = "consul.agent.service.deregister"
+	TraceSpanAgentMaintenanceEnable  = "consul.agent.service.maintenance.enable"
+	TraceSpanAgentMaintenanceDisable = "consul.agent.service.maintenance.disable"
+	TraceSpanAgentCheckRegister      = "consul.agent.check.register"
+	TraceSpanAgentChecks             = "consul.agent.checks"
+	TraceSpanHealthChecks            = "consul.health.checks"
+)
+
+// newTracer returns a tracer from the provided provider, or a no-op tracer if tp is nil
+func newTracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = noop.NewTracerProvider()
+	}
+	return tp.Tracer(TracerName)
+}
+
+// startSpan starts a client span around a call to Consul.  If ctx carries a span, the new span will be its child.
+func startSpan(ctx context.Context, tr trace.Tracer, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tr.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// endSpan ends the provided span, recording err if not nil
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,154 @@
+package consultant_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/myENA/consultant/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordingTracerProvider is a minimal in-memory trace.TracerProvider recording the name, attributes, and parent of
+// every span ended
+type recordingTracerProvider struct {
+	noop.TracerProvider
+
+	mu    sync.Mutex
+	ended []*recordingSpan
+}
+
+func (tp *recordingTracerProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return &recordingTracer{tp: tp}
+}
+
+func (tp *recordingTracerProvider) Ended() []*recordingSpan {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	out := make([]*recordingSpan, len(tp.ended))
+	copy(out, tp.ended)
+	return out
+}
+
+type recordingTracer struct {
+	noop.Tracer
+
+	tp *recordingTracerProvider
+}
+
+func (tr *recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	span := &recordingSpan{tp: tr.tp, name: name, attrs: make(map[string]string)}
+	if parent, ok := trace.SpanFromContext(ctx).(*recordingSpan); ok {
+		span.parent = parent.name
+	}
+	span.SetAttributes(cfg.Attributes()...)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+type recordingSpan struct {
+	noop.Span
+
+	tp     *recordingTracerProvider
+	name   string
+	parent string
+
+	mu    sync.Mutex
+	attrs map[string]string
+}
+
+func (s *recordingSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range kv {
+		s.attrs[string(a.Key)] = a.Value.Emit()
+	}
+}
+
+func (s *recordingSpan) Attribute(k attribute.Key) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attrs[string(k)]
+}
+
+func (s *recordingSpan) End(...trace.SpanEndOption) {
+	s.tp.mu.Lock()
+	s.tp.ended = append(s.tp.ended, s)
+	s.tp.mu.Unlock()
+}
+
+func TestTracing(t *testing.T) {
+	server, client := makeTestServerAndClient(t, nil)
+	defer stopTestServer(server)
+	server.WaitForSerfCheck(t)
+
+	const parentName = "test.parent"
+
+	tp := new(recordingTracerProvider)
+
+	parentCtx, parent := tp.Tracer("test").Start(context.Background(), parentName)
+	defer parent.End()
+
+	cfg := new(consultant.CandidateConfig)
+	cfg.TracerProvider = tp
+	cfg.TraceContext = parentCtx
+	cand := newCandidateWithServerAndClient(t, cfg, server, client)
+
+	if err := cand.Run(); err != nil {
+		t.Fatalf("Error running candidate: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := cand.WaitUntil(ctx); err != nil {
+		t.Fatalf("Candidate election cycle took longer than expected to complete: %s", err)
+	}
+
+	sid := cand.Session().ID()
+
+	if err := cand.Shutdown(); err != nil {
+		t.Fatalf("Error shutting down candidate: %s", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, span := range tp.Ended() {
+		seen[span.name] = true
+
+		if span.parent != parentName {
+			t.Logf("Expected span %q to be parented to %q, saw %q", span.name, parentName, span.parent)
+			t.Fail()
+		}
+
+		switch span.name {
+		case consultant.TraceSpanSessionCreate, consultant.TraceSpanSessionDestroy:
+			if v := span.Attribute(consultant.TraceAttributeSessionID); v != sid {
+				t.Logf("Expected span %q to have session id %q, saw %q", span.name, sid, v)
+				t.Fail()
+			}
+		case consultant.TraceSpanKVAcquire, consultant.TraceSpanKVDelete:
+			if v := span.Attribute(consultant.TraceAttributeKVKey); v != candidateTestKVKey {
+				t.Logf("Expected span %q to have key %q, saw %q", span.name, candidateTestKVKey, v)
+				t.Fail()
+			}
+			if v := span.Attribute(consultant.TraceAttributeCandidateID); v != candidateTestID {
+				t.Logf("Expected span %q to have candidate id %q, saw %q", span.name, candidateTestID, v)
+				t.Fail()
+			}
+		}
+	}
+
+	for _, name := range []string{
+		consultant.TraceSpanSessionCreate,
+		consultant.TraceSpanSessionDestroy,
+		consultant.TraceSpanKVAcquire,
+		consultant.TraceSpanKVDelete,
+	} {
+		if !seen[name] {
+			t.Logf("Expected to see span %q", name)
+			t.Fail()
+		}
+	}
+}
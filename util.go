@@ -7,6 +7,7 @@ import (
 	"net"
 	"net/url"
 	"os"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -66,23 +67,22 @@ func init() {
 	}
 }
 
+// Logger is the original Printf-only logger accepted by managed types.  It is wrapped with NewPrintfLogger when
+// provided in place of a StructuredLogger.
 type Logger interface {
 	Printf(string, ...interface{})
 }
 
+// loggerWriter adapts a StructuredLogger for use as the output of a log.Logger, writing each line at info level
 type loggerWriter struct {
-	logger Logger
+	logger StructuredLogger
 }
 
 func (lw *loggerWriter) Write(b []byte) (int, error) {
-	if lw.logger == nil {
-		return 0, nil
-	}
 	l := len(b)
-	if l == 0 {
-		return 0, nil
+	if m := strings.TrimSpace(string(b)); m != "" {
+		lw.logger.Info(m)
 	}
-	lw.logger.Printf(string(b))
 	return l, nil
 }
 
@@ -199,6 +199,19 @@ func strSlicesEqual(a, b []string) bool {
 	return true
 }
 
+// determines if a and b contain the same key / value pairs.  nil and empty maps are considered equal.
+func strMapsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
 // SpecificServiceEntry attempts to find a specific service's entry from the health endpoint
 func SpecificServiceEntry(serviceID string, svcs []*api.ServiceEntry) (*api.ServiceEntry, bool) {
 	for _, svc := range svcs {
@@ -230,11 +243,79 @@ func SpecificChecks(serviceID string, checks api.HealthChecks) api.HealthChecks
 	return myChecks
 }
 
+// defaultServiceCheckID returns the id the agent assigns to the i-th of n checks registered alongside a service when the
+// check itself did not define one
+func defaultServiceCheckID(serviceID string, i, n int) string {
+	if n == 1 {
+		return "service:" + serviceID
+	}
+	return fmt.Sprintf("service:%s:%d", serviceID, i+1)
+}
+
+// agentCheckDefinition builds a registrable check definition from the provided agent check.  Attributes the agent does
+// not expose are sourced from known, which may be nil.  If the resulting definition is not registrable, nil is returned.
+func agentCheckDefinition(ac *api.AgentCheck, known *api.AgentServiceCheck) *api.AgentServiceCheck {
+	def := new(api.AgentServiceCheck)
+	if known != nil {
+		*def = *known
+	}
+
+	def.CheckID = ac.CheckID
+	def.Name = ac.Name
+	def.Notes = ac.Notes
+	def.Status = ac.Status
+
+	switch ac.Type {
+	case "http":
+		def.HTTP = ac.Definition.HTTP
+		def.Header = ac.Definition.Header
+		def.Method = ac.Definition.Method
+		def.Body = ac.Definition.Body
+		def.TLSServerName = ac.Definition.TLSServerName
+		def.TLSSkipVerify = ac.Definition.TLSSkipVerify
+	case "tcp":
+		def.TCP = ac.Definition.TCP
+		def.TCPUseTLS = ac.Definition.TCPUseTLS
+	case "udp":
+		def.UDP = ac.Definition.UDP
+	case "grpc":
+		def.GRPC = ac.Definition.GRPC
+		def.GRPCUseTLS = ac.Definition.GRPCUseTLS
+	}
+
+	if d := ac.Definition.IntervalDuration; d > 0 {
+		def.Interval = d.String()
+	}
+	if d := ac.Definition.TimeoutDuration; d > 0 {
+		def.Timeout = d.String()
+	}
+	if d := ac.Definition.DeregisterCriticalServiceAfterDuration; d > 0 {
+		def.DeregisterCriticalServiceAfter = d.String()
+	}
+
+	if def.HTTP == "" && def.TCP == "" && def.UDP == "" && def.GRPC == "" && def.TTL == "" && len(def.Args) == 0 &&
+		def.AliasService == "" && def.H2PING == "" {
+		return nil
+	}
+
+	return def
+}
+
 // IsNotFoundErr performs a simple test to see if the provided error describes a "404 not found" response from an agent
 func IsNotFoundError(err error) bool {
 	return err != nil && strings.HasPrefix(err.Error(), notFoundErrPrefix)
 }
 
+// isRetryableError returns true if the provided error is either a transport error or a 5xx response from the agent,
+// either of which may succeed if retried.  Any other response, such as a 400 or 403, is considered permanent.
+func isRetryableError(err error) bool {
+	var se api.StatusError
+	if errors.As(err, &se) {
+		return se.Code >= 500
+	}
+	return true
+}
+
 // SlugParams is used by the ReplaceSlugs helper function
 type SlugParams struct {
 	Name string
@@ -254,6 +335,48 @@ func ReplaceSlugs(s string, p SlugParams) string {
 	return s
 }
 
+// SlugPattern compiles a regular expression matching any string ReplaceSlugs could produce from the provided format and
+// params.  !RAND! and !UNIX! slugs match any value they could be replaced with.
+func SlugPattern(s string, p SlugParams) (*regexp.Regexp, error) {
+	s = regexp.QuoteMeta(s)
+	s = strings.ReplaceAll(s, regexp.QuoteMeta(SlugRand), "[0-9a-zA-Z]{12}")
+	s = strings.ReplaceAll(s, regexp.QuoteMeta(SlugUnix), "[0-9]+")
+	s = strings.ReplaceAll(s, regexp.QuoteMeta(SlugName), regexp.QuoteMeta(p.Name))
+	s = strings.ReplaceAll(s, regexp.QuoteMeta(SlugAddr), regexp.QuoteMeta(p.Addr))
+	s = strings.ReplaceAll(s, regexp.QuoteMeta(SlugNode), regexp.QuoteMeta(p.Node))
+	return regexp.Compile("^" + s + "$")
+}
+
+// validateServiceNameAndPort performs basic service name cleanup and validation, returning the cleaned name
+func validateServiceNameAndPort(name string, port int) (string, error) {
+	serviceName := strings.TrimSpace(name)
+	if serviceName == "" {
+		return "", errors.New("\"Name\" cannot be blank")
+	}
+	if strings.Contains(serviceName, " ") {
+		return "", fmt.Errorf("name \"%s\" is invalid, service names cannot contain spaces", serviceName)
+	}
+
+	// Come on, guys...valid ports plz...
+	if port <= 0 {
+		return "", fmt.Errorf("%d is not a valid port", port)
+	}
+
+	return serviceName, nil
+}
+
+// defaultSimpleServiceID forms a unique service id from the service name and either the local hostname or a random
+// string
+func defaultSimpleServiceID(serviceName, localHostname string, random bool) string {
+	var tail string
+	if random {
+		tail = LazyRandomString(12)
+	} else {
+		tail = strings.ToLower(localHostname)
+	}
+	return fmt.Sprintf("%s-%s", serviceName, tail)
+}
+
 func simpleToReg(localAddr, localHostname string, reg *SimpleServiceRegistration) (string, *api.AgentServiceRegistration, error) {
 	var (
 		serviceID   string                 // local service identifier
@@ -263,18 +386,10 @@ func simpleToReg(localAddr, localHostname string, reg *SimpleServiceRegistration
 		serviceName string                 // service registration name
 	)
 
-	// Perform some basic service name cleanup and validation
-	serviceName = strings.TrimSpace(reg.Name)
-	if serviceName == "" {
-		return "", nil, errors.New("\"Name\" cannot be blank")
-	}
-	if strings.Contains(serviceName, " ") {
-		return "", nil, fmt.Errorf("name \"%s\" is invalid, service names cannot contain spaces", serviceName)
-	}
+	var err error
 
-	// Come on, guys...valid ports plz...
-	if reg.Port <= 0 {
-		return "", nil, fmt.Errorf("%d is not a valid port", reg.Port)
+	if serviceName, err = validateServiceNameAndPort(reg.Name, reg.Port); err != nil {
+		return "", nil, err
 	}
 
 	if address = reg.Address; address == "" {
@@ -282,14 +397,7 @@ func simpleToReg(localAddr, localHostname string, reg *SimpleServiceRegistration
 	}
 
 	if serviceID = reg.ID; serviceID == "" {
-		// Form a unique service id
-		var tail string
-		if reg.RandomID {
-			tail = LazyRandomString(12)
-		} else {
-			tail = strings.ToLower(localHostname)
-		}
-		serviceID = fmt.Sprintf("%s-%s", serviceName, tail)
+		serviceID = defaultSimpleServiceID(serviceName, localHostname, reg.RandomID)
 	}
 
 	if interval = reg.Interval; interval == "" {
@@ -367,3 +475,121 @@ func buildDefaultSessionName(base *api.SessionEntry) string {
 	}
 	return ReplaceSlugs(SessionDefaultNameFormat, SlugParams{Node: nodeName})
 }
+
+// cloneServiceRegistration creates a copy of the provided service definition that shares no slices, maps, checks,
+// weights, or proxy or connect configuration with the original.
+func cloneServiceRegistration(reg *api.AgentServiceRegistration) *api.AgentServiceRegistration {
+	out := new(api.AgentServiceRegistration)
+	*out = *reg
+	if reg.Tags != nil {
+		out.Tags = make([]string, len(reg.Tags))
+		copy(out.Tags, reg.Tags)
+	}
+	if reg.Meta != nil {
+		out.Meta = make(map[string]string, len(reg.Meta))
+		for k, v := range reg.Meta {
+			out.Meta[k] = v
+		}
+	}
+	if reg.TaggedAddresses != nil {
+		out.TaggedAddresses = make(map[string]api.ServiceAddress, len(reg.TaggedAddresses))
+		for k, v := range reg.TaggedAddresses {
+			out.TaggedAddresses[k] = v
+		}
+	}
+	if reg.Weights != nil {
+		w := *reg.Weights
+		out.Weights = &w
+	}
+	if reg.Check != nil {
+		c := *reg.Check
+		out.Check = &c
+	}
+	if reg.Checks != nil {
+		out.Checks = make(api.AgentServiceChecks, len(reg.Checks))
+		for i, c := range reg.Checks {
+			cc := *c
+			out.Checks[i] = &cc
+		}
+	}
+	if reg.Proxy != nil {
+		out.Proxy = new(api.AgentServiceConnectProxyConfig)
+		*out.Proxy = *reg.Proxy
+		if reg.Proxy.Upstreams != nil {
+			out.Proxy.Upstreams = make([]api.Upstream, len(reg.Proxy.Upstreams))
+			copy(out.Proxy.Upstreams, reg.Proxy.Upstreams)
+		}
+	}
+	if reg.Connect != nil {
+		out.Connect = new(api.AgentServiceConnect)
+		*out.Connect = *reg.Connect
+		if reg.Connect.SidecarService != nil {
+			out.Connect.SidecarService = cloneServiceRegistration(reg.Connect.SidecarService)
+		}
+	}
+	return out
+}
+
+// healthSeverity orders check statuses from best to worst.  Unknown statuses, including maintenance, are considered
+// critical.
+func healthSeverity(status string) int {
+	switch status {
+	case api.HealthPassing:
+		return 0
+	case api.HealthWarning:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// worseHealth returns whichever of the two provided statuses is worse, normalizing anything unknown to critical
+func worseHealth(a, b string) string {
+	if healthSeverity(b) > healthSeverity(a) {
+		a = b
+	}
+	if healthSeverity(a) == 2 {
+		return api.HealthCritical
+	}
+	return a
+}
+
+// agentServiceFromRegistration synthesizes the agent's view of the provided registration, used in place of the real
+// thing until it has been registered
+func agentServiceFromRegistration(serviceID string, reg *api.AgentServiceRegistration) *api.AgentService {
+	svc := &api.AgentService{
+		Kind:              reg.Kind,
+		ID:                serviceID,
+		Service:           reg.Name,
+		Port:              reg.Port,
+		Address:           reg.Address,
+		Proxy:             reg.Proxy,
+		EnableTagOverride: reg.EnableTagOverride,
+	}
+	if reg.Tags != nil {
+		svc.Tags = make([]string, len(reg.Tags))
+		copy(svc.Tags, reg.Tags)
+	}
+	if reg.Meta != nil {
+		svc.Meta = make(map[string]string, len(reg.Meta))
+		for k, v := range reg.Meta {
+			svc.Meta[k] = v
+		}
+	}
+	if reg.TaggedAddresses != nil {
+		svc.TaggedAddresses = make(map[string]api.ServiceAddress, len(reg.TaggedAddresses))
+		for k, v := range reg.TaggedAddresses {
+			svc.TaggedAddresses[k] = v
+		}
+	}
+	if reg.Weights != nil {
+		svc.Weights = *reg.Weights
+	} else {
+		svc.Weights = api.AgentWeights{Passing: 1, Warning: 1}
+	}
+	if reg.Connect != nil {
+		// the sidecar is tracked separately
+		svc.Connect = &api.AgentServiceConnect{Native: reg.Connect.Native}
+	}
+	return svc
+}
@@ -74,3 +74,25 @@ func TestRandomString(t *testing.T) {
 		}
 	})
 }
+
+func TestSlugPattern(t *testing.T) {
+	params := consultant.SlugParams{Name: "web", Addr: "10.0.0.1"}
+	pattern, err := consultant.SlugPattern(consultant.ServiceDefaultIDFormat, params)
+	if err != nil {
+		t.Fatalf("Error compiling pattern: %s", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if id := consultant.ReplaceSlugs(consultant.ServiceDefaultIDFormat, params); !pattern.MatchString(id) {
+			t.Logf("Expected pattern %s to match %q", pattern, id)
+			t.Fail()
+		}
+	}
+
+	for _, id := range []string{"web-10.0.0.1", "web-10.0.0.2-abcdefABCDEF", "api-10.0.0.1-abcdefABCDEF", "web-10a0a0a1-abcdefABCDEF"} {
+		if pattern.MatchString(id) {
+			t.Logf("Expected pattern %s not to match %q", pattern, id)
+			t.Fail()
+		}
+	}
+}